@@ -0,0 +1,182 @@
+package app
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/anmitsu/goful/archive"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/util"
+)
+
+// archiveNames returns slash entry names of the virtual paths in the archive,
+// or nil if any path is not in the archive.
+func archiveNames(arc string, paths []string) []string {
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		rel, err := filepath.Rel(arc, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		names[i] = filepath.ToSlash(rel)
+	}
+	return names
+}
+
+// extract copies the entries of the names in the archive directory and their
+// children to the destination directory as a job.  Existing files are resolved
+// in the conflict dialog.
+func (g *Goful) extract(dst, arc, dir string, names []string) {
+	dstAbs := g.abs(dst)
+	g.asyncFilectrl(jobCopy, dstAbs, []string{arc}, func(j *job) error {
+		policy := overwriteNo
+		if err := g.letExtract(j, &policy, dstAbs, arc, dir, names); err != nil {
+			return err
+		}
+		message.Infof("Copied to %s from %s", dstAbs, arc)
+		return nil
+	})
+}
+
+// extractArchives extracts all entries of the archive files to the
+// destination directory as a job.  Existing files are resolved in the conflict
+// dialog shared by the archives.
+func (g *Goful) extractArchives(dst string, arcs ...string) {
+	dstAbs := g.abs(dst)
+	arcsAbs := make([]string, len(arcs))
+	for i, arc := range arcs {
+		arcsAbs[i] = g.abs(arc)
+		if !archive.IsArchive(arc) {
+			message.Errorf("Not supported archive %s", arc)
+			return
+		}
+	}
+	g.asyncFilectrl(jobExtract, dstAbs, arcsAbs, func(j *job) error {
+		policy := overwriteNo
+		for _, arc := range arcsAbs {
+			if err := g.letExtract(j, &policy, dstAbs, arc, "", nil); err != nil {
+				return err
+			}
+		}
+		message.Infof("Extracted %s to %s", arcsAbs, dstAbs)
+		return nil
+	})
+}
+
+func (g *Goful) letExtract(j *job, policy *overWrite, dst, arc, dir string, names []string) error {
+	entries, err := archive.List(arc)
+	if err != nil {
+		return err
+	}
+	var size int64
+	count := 0
+	for _, e := range archive.Select(entries, names) {
+		if e.Mode.IsRegular() {
+			size += e.Size
+			count++
+		}
+	}
+	atomic.AddInt64(&j.total, size)
+	j.progress = progress.Start(float64(size), count)
+	defer j.progress.Finish()
+	defer drawProgress()()
+
+	x := &archive.Extractor{
+		Resolve: func(e *archive.Entry, target string) (string, error) {
+			return g.resolveConflict(j, policy, e.Info(), target)
+		},
+		Copy: func(e *archive.Entry, w io.Writer, r io.Reader) (int64, error) {
+			return copyProgress(j, e.Info(), w, r)
+		},
+	}
+	return x.Extract(arc, dir, names, dst)
+}
+
+// archive creates the archive file of the files as a job in the format by the
+// extension.  An existing archive file is resolved in the conflict dialog.
+func (g *Goful) archive(dst string, src ...string) {
+	dstAbs := g.abs(dst)
+	srcAbs := make([]string, len(src))
+	for i := range src {
+		srcAbs[i] = g.abs(src[i])
+	}
+	if !archive.IsArchive(dstAbs) {
+		message.Errorf("Not supported archive %s", dst)
+		return
+	}
+	g.asyncFilectrl(jobArchive, dstAbs, srcAbs, func(j *job) error {
+		size, count := util.CalcSizeCount(srcAbs...)
+		target := dstAbs // resolved in each run not to rename the retried job
+		if _, err := os.Lstat(target); err == nil {
+			policy := overwriteNo
+			info := archiveInfo{filepath.Base(dstAbs), size, time.Now()}
+			resolved, err := g.resolveConflict(j, &policy, info, target)
+			if err != nil || resolved == "" {
+				return err
+			}
+			target = resolved
+		}
+		atomic.StoreInt64(&j.total, size)
+		j.progress = progress.Start(float64(size), count)
+		defer j.progress.Finish()
+		defer drawProgress()()
+
+		a := &archive.Archiver{
+			Copy: func(fi os.FileInfo, w io.Writer, r io.Reader) (int64, error) {
+				return copyProgress(j, fi, w, r)
+			},
+		}
+		if err := a.Create(target, srcAbs); err != nil {
+			return err
+		}
+		message.Infof("Archived %s to %s", srcAbs, target)
+		return nil
+	})
+}
+
+// archiveInfo is the file info of an archive to be created for the conflict
+// dialog, having the total size of the files to archive.
+type archiveInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i archiveInfo) Name() string       { return i.name }
+func (i archiveInfo) Size() int64        { return i.size }
+func (i archiveInfo) Mode() os.FileMode  { return 0644 }
+func (i archiveInfo) ModTime() time.Time { return i.modTime }
+func (i archiveInfo) IsDir() bool        { return false }
+func (i archiveInfo) Sys() interface{}   { return nil }
+
+// copyProgress copies contents of the file updating the job progress.
+func copyProgress(j *job, fi os.FileInfo, w io.Writer, r io.Reader) (int64, error) {
+	j.progress.StartTask(fi)
+	defer j.progress.FinishTask()
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		if err := j.wait(); err != nil {
+			return written, err
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+			j.progress.Update(float64(n))
+			atomic.AddInt64(&j.done, int64(n))
+		}
+		if err == io.EOF {
+			return written, nil
+		} else if err != nil {
+			return written, err
+		}
+	}
+}
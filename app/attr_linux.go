@@ -0,0 +1,63 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func fileAtime(fi os.FileInfo) time.Time {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	}
+	return fi.ModTime()
+}
+
+// copyXattrs copies extended attributes not following symlinks.  Unsupported
+// attributes on the destination filesystem are ignored.
+func copyXattrs(src, dst string) error {
+	size, err := unix.Llistxattr(src, nil)
+	if err != nil || size == 0 {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil
+		}
+		return wrapXattrErr("llistxattr", src, err)
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(src, buf)
+	if err != nil {
+		return wrapXattrErr("llistxattr", src, err)
+	}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		size, err := unix.Lgetxattr(src, attr, nil)
+		if err != nil {
+			return wrapXattrErr("lgetxattr", src, err)
+		}
+		value := make([]byte, size)
+		if size, err = unix.Lgetxattr(src, attr, value); err != nil {
+			return wrapXattrErr("lgetxattr", src, err)
+		}
+		if err := unix.Lsetxattr(dst, attr, value[:size], 0); err != nil {
+			if errors.Is(err, unix.ENOTSUP) {
+				continue
+			}
+			return wrapXattrErr("lsetxattr "+attr, dst, err)
+		}
+	}
+	return nil
+}
+
+func wrapXattrErr(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package app
+
+import (
+	"os"
+	"time"
+)
+
+func fileAtime(fi os.FileInfo) time.Time { return fi.ModTime() }
+
+func copyXattrs(src, dst string) error { return nil }
@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkKey identifies a file by the device and the inode number.
+type linkKey struct {
+	dev uint64
+	ino uint64
+}
+
+// fileLinkKey returns the key and the number of hard links of the file.
+func fileLinkKey(fi os.FileInfo) (linkKey, uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return linkKey{}, 0, false
+	}
+	return linkKey{uint64(st.Dev), uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// chownLike changes the owner of the path to the owner of the file stat
+// without following symlinks.
+func chownLike(fi os.FileInfo, path string) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return os.Lchown(path, int(st.Uid), int(st.Gid))
+}
+
+// isSparse reports whether the file has fewer allocated blocks than the size.
+func isSparse(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return st.Blocks*512 < st.Size
+}
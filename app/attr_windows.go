@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package app
+
+import "os"
+
+type linkKey struct{}
+
+func fileLinkKey(fi os.FileInfo) (linkKey, uint64, bool) { return linkKey{}, 0, false }
+
+func chownLike(fi os.FileInfo, path string) error { return nil }
+
+func isSparse(fi os.FileInfo) bool { return false }
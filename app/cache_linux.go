@@ -0,0 +1,12 @@
+package app
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropCache drops the page cache of the file to read from the device.
+func dropCache(file *os.File) {
+	_ = unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package app
+
+import "os"
+
+func dropCache(file *os.File) {}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/anmitsu/goful/filer"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/vfs"
+)
+
+// Compare marks files that exist only in one side or differ between the
+// focused directory and the next directory.  Files differ by the type, the size
+// and the modification time in seconds, or by the SHA-256 hash of contents
+// instead of the time if content is true.  Directories differ if any files in
+// the trees differ.  Trees and contents are compared in a job, which can be
+// canceled, and files are marked after comparing.
+func (g *Goful) Compare(content bool) {
+	left, right := g.Dir(), g.Workspace().NextDir()
+	if content && !g.localOnly(left, right) {
+		return
+	}
+	if left.Path == right.Path {
+		message.Errorf("Cannot compare %s with itself", left.Path)
+		return
+	}
+	left.MarkClear()
+	right.MarkClear()
+	lfiles, rfiles := compareFiles(left), compareFiles(right)
+
+	count := 0
+	mark := func(files ...*filer.FileStat) {
+		for _, fs := range files {
+			fs.Mark()
+			count++
+		}
+	}
+	pending := []string{} // names to compare the trees or the contents
+	for name, l := range lfiles {
+		r, ok := rfiles[name]
+		if !ok {
+			mark(l)
+			continue
+		}
+		switch compareStat(left.FS(), right.FS(), l.Path(), r.Path(), l, r, content) {
+		case compareDiffer:
+			mark(l, r)
+		case compareTree, compareContent:
+			pending = append(pending, name)
+		}
+	}
+	for name, r := range rfiles {
+		if _, ok := lfiles[name]; !ok {
+			mark(r)
+		}
+	}
+	if len(pending) < 1 {
+		message.Infof("Compared %s and %s: %d files marked", left.Path, right.Path, count)
+		return
+	}
+
+	lfs, rfs, lpath, rpath := left.FS(), right.FS(), left.Path, right.Path
+	g.asyncFilectrl(jobCompare, rpath, []string{lpath}, func(j *job) error {
+		var size int64
+		files := 0
+		if content {
+			for _, name := range pending {
+				lsize, lcount := vfs.SizeCount(lfs, filepath.Join(lpath, name))
+				rsize, rcount := vfs.SizeCount(rfs, filepath.Join(rpath, name))
+				size, files = size+lsize+rsize, files+lcount+rcount
+			}
+		}
+		atomic.StoreInt64(&j.total, size)
+		j.progress = progress.Start(float64(size), files)
+		defer j.progress.Finish()
+
+		differ := []string{}
+		for _, name := range pending {
+			same, err := samePath(j, lfs, rfs, filepath.Join(lpath, name), filepath.Join(rpath, name), content)
+			if err != nil {
+				if werr := j.wait(); werr != nil {
+					return werr
+				}
+				message.Error(err)
+				continue
+			}
+			if !same {
+				differ = append(differ, name)
+			}
+		}
+		g.syncCallback(func() {
+			// mark files listed now unless changed directories
+			if left.Path == lpath && right.Path == rpath {
+				lfiles, rfiles := compareFiles(left), compareFiles(right)
+				for _, name := range differ {
+					for _, fs := range []*filer.FileStat{lfiles[name], rfiles[name]} {
+						if fs != nil {
+							mark(fs)
+						}
+					}
+				}
+			}
+			message.Infof("Compared %s and %s: %d files marked", lpath, rpath, count)
+		})
+		return nil
+	})
+}
+
+// compareFiles returns files of the directory by the name excluding "..".
+func compareFiles(d *filer.Directory) map[string]*filer.FileStat {
+	files := make(map[string]*filer.FileStat, len(d.List()))
+	for _, e := range d.List() {
+		fs := e.(*filer.FileStat)
+		if fs.Name() != ".." {
+			files[fs.Name()] = fs
+		}
+	}
+	return files
+}
+
+type compareResult int
+
+const (
+	compareSame compareResult = iota
+	compareDiffer
+	compareContent // need to compare contents
+	compareTree    // need to compare directory trees
+)
+
+func compareStat(lfs, rfs vfs.FS, lpath, rpath string, l, r os.FileInfo, content bool) compareResult {
+	switch {
+	case l.Mode().Type() != r.Mode().Type():
+		return compareDiffer
+	case l.IsDir():
+		return compareTree
+	case l.Mode()&os.ModeSymlink != 0:
+		llink, err1 := readlink(lfs, lpath)
+		rlink, err2 := readlink(rfs, rpath)
+		if err1 != nil || err2 != nil || llink != rlink {
+			return compareDiffer
+		}
+		return compareSame
+	case l.Size() != r.Size():
+		return compareDiffer
+	case content && l.Mode().IsRegular():
+		return compareContent
+	case l.ModTime().Unix() != r.ModTime().Unix():
+		return compareDiffer
+	}
+	return compareSame
+}
+
+func readlink(fsys vfs.FS, path string) (string, error) {
+	if l, ok := fsys.(vfs.Linker); ok {
+		return l.Readlink(path)
+	}
+	return "", &os.PathError{Op: "readlink", Path: path, Err: errNoSymlinks}
+}
+
+var errNoSymlinks = errors.New("symlinks not supported")
+
+// samePath reports whether the files are the same, or the directory trees
+// have the same files walking them like compareDir.
+func samePath(j *job, lfs, rfs vfs.FS, lpath, rpath string, content bool) (bool, error) {
+	if err := j.wait(); err != nil {
+		return false, err
+	}
+	l, err := lfs.Lstat(lpath)
+	if err != nil {
+		return false, err
+	}
+	r, err := rfs.Lstat(rpath)
+	if err != nil {
+		return false, err
+	}
+	switch compareStat(lfs, rfs, lpath, rpath, l, r, content) {
+	case compareSame:
+		return true, nil
+	case compareDiffer:
+		return false, nil
+	case compareContent:
+		return sameContent(j, lfs, rfs, lpath, rpath)
+	}
+
+	lfiles, err := readDirMap(lfs, lpath)
+	if err != nil {
+		return false, err
+	}
+	rfiles, err := readDirMap(rfs, rpath)
+	if err != nil {
+		return false, err
+	}
+	if len(lfiles) != len(rfiles) {
+		return false, nil
+	}
+	names := make([]string, 0, len(lfiles))
+	for name := range lfiles {
+		if _, ok := rfiles[name]; !ok {
+			return false, nil
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		same, err := samePath(j, lfs, rfs, filepath.Join(lpath, name), filepath.Join(rpath, name), content)
+		if err != nil || !same {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// sameContent reports whether the files have the same SHA-256 hash.
+func sameContent(j *job, fs1, fs2 vfs.FS, path1, path2 string) (bool, error) {
+	sum1, err := hashFile(j, fs1, path1)
+	if err != nil {
+		return false, err
+	}
+	sum2, err := hashFile(j, fs2, path2)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(sum1, sum2), nil
+}
+
+// hashFile returns the SHA-256 hash of the file checking the job context while
+// reading.
+func hashFile(j *job, fsys vfs.FS, path string) ([]byte, error) {
+	stat, err := fsys.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := copyProgress(j, stat, h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
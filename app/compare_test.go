@@ -0,0 +1,57 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anmitsu/goful/filer"
+	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/vfs"
+)
+
+func TestCompareStat(t *testing.T) {
+	left, right := t.TempDir(), t.TempDir()
+	for dir, files := range map[string]map[string]string{
+		left:  {"same": "abc", "size": "a", "content": "abc", "dir/a": "a", "tree/a": "a"},
+		right: {"same": "abc", "size": "ab", "content": "xyz", "dir/a": "a", "tree/a": "b"},
+	} {
+		for name, data := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	j := newJob(jobCompare, right, []string{left}, nil)
+	j.progress = progress.Start(0, 0)
+	defer j.progress.Finish()
+	for _, c := range []struct {
+		name string
+		want compareResult
+		same bool
+	}{
+		{"same", compareContent, true},
+		{"size", compareDiffer, false},
+		{"content", compareContent, false},
+		{"dir", compareTree, true},
+		{"tree", compareTree, false},
+	} {
+		l, r := filer.NewFileStat(left, c.name), filer.NewFileStat(right, c.name)
+		if got := compareStat(vfs.Local, vfs.Local, l.Path(), r.Path(), l, r, true); got != c.want {
+			t.Errorf("compareStat(%s)=%d, want %d", c.name, got, c.want)
+		}
+		if same, err := samePath(j, vfs.Local, vfs.Local, l.Path(), r.Path(), true); err != nil || same != c.same {
+			t.Errorf("samePath(%s)=%v, %v, want %v", c.name, same, err, c.same)
+		}
+	}
+
+	j.cancel()
+	if _, err := samePath(j, vfs.Local, vfs.Local, filepath.Join(left, "dir"), filepath.Join(right, "dir"), true); err == nil {
+		t.Errorf("samePath() after canceled returns nil")
+	}
+}
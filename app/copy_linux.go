@@ -0,0 +1,79 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+const copyRangeChunk = 1 << 20 // check canceling for each chunk
+
+// fastCopy copies from the offset by the FICLONE reflink, or by
+// copy_file_range for data segments skipping holes.  Returns false if not supported and nothing is
+// written.
+func fastCopy(j *job, srcfile, dstfile *os.File, offset, size int64) (bool, error) {
+	srcfd, dstfd := int(srcfile.Fd()), int(dstfile.Fd())
+	if offset == 0 {
+		if err := unix.IoctlFileClone(dstfd, srcfd); err == nil {
+			j.progress.Update(float64(size))
+			atomic.AddInt64(&j.done, size)
+			return true, nil
+		}
+	}
+
+	copied := false
+	skipped := int64(0) // holes counted after copying data not to count twice falling back
+	off := offset
+	for off < size {
+		data, err := unix.Seek(srcfd, off, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) { // trailing hole
+			break
+		} else if err != nil {
+			if copied {
+				return true, err
+			}
+			data = off // SEEK_DATA is not supported
+		}
+		hole, err := unix.Seek(srcfd, data, unix.SEEK_HOLE)
+		if err != nil {
+			hole = size
+		}
+		skipped += data - off
+		for data < hole {
+			if err := j.wait(); err != nil {
+				return true, err
+			}
+			n := hole - data
+			if n > copyRangeChunk {
+				n = copyRangeChunk
+			}
+			roff, woff := data, data
+			written, err := unix.CopyFileRange(srcfd, &roff, dstfd, &woff, int(n), 0)
+			if err != nil {
+				if !copied { // such as EXDEV, ENOSYS and EOPNOTSUPP
+					return false, nil
+				}
+				return true, &os.PathError{Op: "copy_file_range", Path: dstfile.Name(), Err: err}
+			}
+			if written == 0 {
+				if !copied { // not supported by the filesystem
+					return false, nil
+				}
+				hole = data // the source is truncated
+				size = data
+				break
+			}
+			copied = true
+			data += int64(written)
+			j.progress.Update(float64(int64(written) + skipped))
+			atomic.AddInt64(&j.done, int64(written)+skipped)
+			skipped = 0
+		}
+		off = hole
+	}
+	j.progress.Update(float64(size - off + skipped))
+	atomic.AddInt64(&j.done, size-off+skipped)
+	return true, dstfile.Truncate(size)
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// duNode is a file or a directory in the disk usage tree with the cumulative
+// size and file count.
+type duNode struct {
+	name     string // base name or the full path as the root
+	size     int64
+	count    int
+	dir      bool
+	err      error // error reading the directory
+	parent   *duNode
+	children []*duNode // sorted by size in descending order
+}
+
+// path returns the full path of the node.
+func (n *duNode) path() string {
+	if n.parent == nil {
+		return n.name
+	}
+	return filepath.Join(n.parent.path(), n.name)
+}
+
+// lookup returns the descendant node of the path, the node itself if the
+// same path, or nil.
+func (n *duNode) lookup(path string) *duNode {
+	rel, err := filepath.Rel(n.path(), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	if rel == "." {
+		return n
+	}
+	node := n
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		var next *duNode
+		for _, c := range node.children {
+			if c.name == name {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// sortChildren sorts the children by size in descending order and by name.
+func (n *duNode) sortChildren() {
+	sort.SliceStable(n.children, func(i, j int) bool {
+		a, b := n.children[i], n.children[j]
+		if a.size != b.size {
+			return a.size > b.size
+		}
+		return a.name < b.name
+	})
+}
+
+// add adds the size and the count to the node and the ancestors keeping the
+// order of children.
+func (n *duNode) add(size int64, count int) {
+	for p := n; p != nil; p = p.parent {
+		p.size += size
+		p.count += count
+		if p.parent != nil {
+			p.parent.sortChildren()
+		}
+	}
+}
+
+// replace replaces the contents of the node with the rescanned node.
+func (n *duNode) replace(m *duNode) {
+	size, count := m.size-n.size, m.count-n.count
+	n.children = m.children
+	for _, c := range n.children {
+		c.parent = n
+	}
+	n.dir, n.err = m.dir, m.err
+	n.add(size, count)
+}
+
+// detach removes the node from the parent subtracting the size.
+func (n *duNode) detach() {
+	p := n.parent
+	if p == nil {
+		return
+	}
+	for i, c := range p.children {
+		if c == n {
+			p.children = append(p.children[:i], p.children[i+1:]...)
+			break
+		}
+	}
+	p.add(-n.size, -n.count)
+	n.parent = nil
+}
+
+// duScanner walks a tree concurrently to build disk usage nodes.
+type duScanner struct {
+	ctx   context.Context
+	sem   chan struct{} // limits concurrent directory reads
+	files int64         // scanned file count updated atomically
+	reuse *duNode       // already scanned node to be reused or nil
+
+	reuseParent *duNode // new parent of the reused node
+}
+
+func newDUScanner(ctx context.Context, reuse *duNode) *duScanner {
+	return &duScanner{ctx: ctx, sem: make(chan struct{}, 8), reuse: reuse}
+}
+
+// scan returns the node of the path scanned recursively, or the context error
+// if canceled.  Symlinks are not followed.
+func (s *duScanner) scan(path string) (*duNode, error) {
+	n := &duNode{name: path, dir: true}
+	var reusePath string
+	if s.reuse != nil {
+		reusePath = s.reuse.path()
+	}
+	s.scanDir(n, path, reusePath)
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (s *duScanner) scanDir(n *duNode, path, reusePath string) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		n.err = err
+	}
+	var wg sync.WaitGroup
+	n.children = make([]*duNode, len(fis))
+	for i, fi := range fis {
+		child := &duNode{name: fi.Name(), dir: fi.IsDir(), parent: n}
+		n.children[i] = child
+		childPath := filepath.Join(path, fi.Name())
+		switch {
+		case childPath == reusePath:
+			s.reuseParent = n
+			n.children[i] = s.reuse
+		case fi.IsDir():
+			select {
+			case s.sem <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.scanDir(child, childPath, reusePath)
+					<-s.sem
+				}()
+			default:
+				s.scanDir(child, childPath, reusePath)
+			}
+		default:
+			child.size = fi.Size()
+			child.count = 1
+			atomic.AddInt64(&s.files, 1)
+		}
+	}
+	wg.Wait()
+	for _, c := range n.children {
+		n.size += c.size
+		n.count += c.count
+	}
+	n.sortChildren()
+}
+
+// attach links the reused node to the new parent.  Call it in the main
+// goroutine after scanning not to change the old tree while displayed.
+func (s *duScanner) attach() {
+	if s.reuse != nil && s.reuseParent != nil {
+		s.reuse.name = filepath.Base(s.reuse.path())
+		s.reuse.parent = s.reuseParent
+	}
+}
+
+// scanned returns the count of scanned files.
+func (s *duScanner) scanned() int64 { return atomic.LoadInt64(&s.files) }
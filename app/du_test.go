@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anmitsu/goful/filer"
+)
+
+func TestDUScan(t *testing.T) {
+	root := t.TempDir()
+	write := func(name string, size int) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("top/small", 10)
+	write("top/big/a", 100)
+	write("top/big/deep/b", 200)
+	write("top/other/c", 50)
+	top := filepath.Join(root, "top")
+
+	sub, err := newDUScanner(context.Background(), nil).scan(filepath.Join(top, "big"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.size != 300 || sub.count != 2 || sub.children[0].name != "deep" {
+		t.Errorf("scanned big: size %d count %d first %s", sub.size, sub.count, sub.children[0].name)
+	}
+
+	// scanning the parent reuses the scanned subtree
+	s := newDUScanner(context.Background(), sub)
+	n, err := s.scan(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.attach()
+	if n.size != 360 || n.count != 4 {
+		t.Errorf("scanned top: size %d count %d", n.size, n.count)
+	}
+	if n.children[0] != sub || sub.name != "big" || sub.path() != filepath.Join(top, "big") {
+		t.Errorf("not reused: %v", n.children[0])
+	}
+	if deep := n.lookup(filepath.Join(top, "big", "deep")); deep == nil || deep.size != 200 {
+		t.Errorf("lookup(big/deep)=%v", deep)
+	}
+	if n.lookup(root) != nil {
+		t.Error("lookup outside returns a node")
+	}
+
+	deep := n.lookup(filepath.Join(top, "big", "deep"))
+	deep.detach()
+	if n.size != 160 || n.count != 3 || n.children[0].name != "big" || sub.size != 100 {
+		t.Errorf("after detach: size %d count %d", n.size, n.count)
+	}
+	other := n.lookup(filepath.Join(top, "other"))
+	write("top/other/d", 500)
+	m, err := newDUScanner(context.Background(), nil).scan(other.path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.replace(m)
+	if n.size != 660 || n.children[0] != other || other.children[0].parent != other {
+		t.Errorf("after replace: size %d first %s", n.size, n.children[0].name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newDUScanner(ctx, nil).scan(top); err == nil {
+		t.Error("scanned without errors after canceled")
+	}
+}
+
+func TestDURemove(t *testing.T) {
+	top := t.TempDir()
+	for _, name := range []string{"a/x", "b/y"} {
+		path := filepath.Join(top, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := NewGoful("")
+	filer.SetSyncCallback(nil) // reload directories in place
+	defer filer.SetSyncCallback(g.syncCallback)
+	g.Dir().Chdir(top)
+	g.DiskUsage()
+	w := g.Next().(*DiskUsage)
+	waitFor(t, g, func() bool { return w.dir != nil && !w.busy() })
+
+	// the failed job scans the parent again keeping the node
+	w.SetCursorByName("a")
+	a := w.current()
+	w.removed(a, errors.New("failed"))
+	waitFor(t, g, func() bool { return !w.busy() })
+	if w.dir.size != 8 || w.dir.lookup(filepath.Join(top, "a")) == nil {
+		t.Errorf("rescanned %d bytes without a", w.dir.size)
+	}
+
+	// the node is detached after removed
+	w.SetCursorByName("a")
+	dialogKeys(g, "y", "C-m")
+	w.Remove()
+	waitFor(t, g, func() bool { return w.dir.size == 4 })
+	if _, err := os.Lstat(filepath.Join(top, "a")); !os.IsNotExist(err) {
+		t.Errorf("%s remains", filepath.Join(top, "a"))
+	}
+	if len(w.dir.children) != 1 || w.dir.children[0].name != "b" {
+		t.Errorf("listed %d nodes after removed", len(w.dir.children))
+	}
+}
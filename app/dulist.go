@@ -0,0 +1,315 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anmitsu/goful/look"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/trash"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/widget"
+	"github.com/mattn/go-runewidth"
+)
+
+var diskUsageKeymap func(*DiskUsage) widget.Keymap
+
+// ConfigDiskUsage sets the disk usage keymap function.
+func ConfigDiskUsage(config func(*DiskUsage) widget.Keymap) {
+	diskUsageKeymap = config
+}
+
+// DiskUsage is a list box of files and directories sorted by cumulative
+// sizes.  Scanned trees are kept for moving directories instantly.
+type DiskUsage struct {
+	*widget.ListBox
+	goful   *Goful
+	dir     *duNode    // listed directory
+	scanner *duScanner // non-nil while scanning
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// DiskUsage starts the disk usage mode of the focused directory.  The last
+// scanned tree is reused if containing the directory.
+func (g *Goful) DiskUsage() {
+	if !g.localOnly(g.Dir()) {
+		return
+	}
+	x, y := g.LeftTop()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &DiskUsage{
+		ListBox: widget.NewListBox(x, y, g.Width(), g.Height(), "Disk usage"),
+		goful:   g,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	g.next = w
+	path := g.Dir().Path
+	if g.duRoot != nil {
+		if n := g.duRoot.lookup(path); n != nil && n.dir {
+			w.show(n, "")
+			return
+		}
+	}
+	w.scan(path, nil, nil, "")
+}
+
+// scan scans the path in the background and replaces the target node with the
+// result, or the root if target is nil.  The reused node is linked in the
+// result instead of scanning again.
+func (w *DiskUsage) scan(path string, target, reuse *duNode, cursor string) {
+	s := newDUScanner(w.ctx, reuse)
+	w.scanner = s
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.goful.syncCallback(func() {}) // redraw the scanned count
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		n, err := s.scan(path)
+		close(done)
+		w.goful.syncCallback(func() {
+			if err != nil || w.ctx.Err() != nil {
+				return
+			}
+			w.scanner = nil
+			s.attach()
+			if target != nil {
+				target.replace(n)
+				n = target
+			} else {
+				w.goful.duRoot = n
+			}
+			w.show(n, cursor)
+			if count := duErrors(n); count > 0 {
+				message.Errorf("Cannot read %d directories in %s", count, path)
+			}
+		})
+	}()
+}
+
+// show lists the directory node and sets the cursor to the name.
+func (w *DiskUsage) show(n *duNode, cursor string) {
+	w.dir = n
+	list := make([]widget.Drawer, len(n.children))
+	for i, c := range n.children {
+		list[i] = &duContent{c, n}
+	}
+	w.SetList(list)
+	if cursor != "" {
+		w.SetCursorByName(cursor)
+		w.SetOffsetCenteredCursor()
+	} else {
+		w.SetCursor(0)
+	}
+}
+
+func (w *DiskUsage) current() *duNode {
+	if w.dir == nil || w.IsEmpty() {
+		return nil
+	}
+	return w.CurrentContent().(*duContent).node
+}
+
+// busy reports whether scanning.
+func (w *DiskUsage) busy() bool { return w.scanner != nil }
+
+// Enter the directory on the cursor.
+func (w *DiskUsage) Enter() {
+	if n := w.current(); !w.busy() && n != nil && n.dir {
+		w.show(n, "")
+	}
+}
+
+// Up moves to the parent directory.  The parent of the scanned root is
+// scanned reusing the root.
+func (w *DiskUsage) Up() {
+	if w.busy() || w.dir == nil {
+		return
+	}
+	if w.dir.parent != nil {
+		w.show(w.dir.parent, w.dir.name)
+		return
+	}
+	path := w.dir.path()
+	if parent := filepath.Dir(path); parent != path {
+		w.scan(parent, nil, w.dir, filepath.Base(path))
+	}
+}
+
+// Rescan the listed directory.
+func (w *DiskUsage) Rescan() {
+	if w.busy() || w.dir == nil {
+		return
+	}
+	cursor := ""
+	if n := w.current(); n != nil {
+		cursor = n.name
+	}
+	w.scan(w.dir.path(), w.dir, nil, cursor)
+}
+
+// Chdir changes the focused directory to the listed directory and exits.
+func (w *DiskUsage) Chdir() {
+	if w.dir == nil {
+		return
+	}
+	w.goful.Dir().Chdir(w.dir.path())
+	w.Exit()
+}
+
+// Trash the file on the cursor as a job after asking, or remove permanently if
+// the trash is unsupported.
+func (w *DiskUsage) Trash() { w.remove(!trash.Supported()) }
+
+// Remove the file on the cursor permanently as a job after asking.
+func (w *DiskUsage) Remove() { w.remove(true) }
+
+func (w *DiskUsage) remove(permanent bool) {
+	n := w.current()
+	if w.busy() || n == nil {
+		return
+	}
+	path := n.path()
+	msg := fmt.Sprintf("%s? %s (%s)", removeAction(permanent), path, util.FormatSize(n.size))
+	if w.goful.dialog(msg, "y", "n") != "y" {
+		return
+	}
+	var j *job
+	if permanent {
+		j = w.goful.remove(path)
+	} else {
+		j = w.goful.trash(path)
+	}
+	j.onFinish = func(err error) { w.removed(n, err) }
+}
+
+// removed updates the tree after the job removing the node finished.  The
+// node is detached if removed, otherwise the parent is scanned again as the
+// node may be removed partly.  The tree is dropped if the mode exited or is
+// scanning.
+func (w *DiskUsage) removed(n *duNode, err error) {
+	parent := n.parent
+	if parent == nil {
+		return
+	}
+	active := w.ctx.Err() == nil && !w.busy()
+	if err != nil {
+		if active {
+			w.scan(parent.path(), parent, nil, n.name)
+		} else {
+			w.goful.duRoot = nil
+		}
+		return
+	}
+	n.detach()
+	if active && w.dir == parent {
+		cursor := w.Cursor()
+		w.show(w.dir, "")
+		w.SetCursor(cursor)
+	}
+}
+
+// Resize the list box to the filer size.
+func (w *DiskUsage) Resize(x, y, width, height int) {
+	w.ListBox.Resize(x, y, width, height)
+}
+
+// Draw the list box and the scanning progress.
+func (w *DiskUsage) Draw() {
+	switch {
+	case w.scanner != nil:
+		w.SetTitle(fmt.Sprintf("Disk usage: scanning... %d files", w.scanner.scanned()))
+	case w.dir != nil:
+		w.SetTitle(fmt.Sprintf("Disk usage: %s %s %d files", util.AbbrPath(w.dir.path()),
+			util.FormatSize(w.dir.size), w.dir.count))
+	}
+	w.ListBox.Draw()
+}
+
+// Input to the list box.
+func (w *DiskUsage) Input(key string) {
+	if callback, ok := diskUsageKeymap(w)[key]; ok {
+		callback()
+	}
+}
+
+// Exit the disk usage mode canceling the scan.
+func (w *DiskUsage) Exit() {
+	w.cancel()
+	w.goful.Disconnect()
+}
+
+// Next implements widget.Widget.
+func (w *DiskUsage) Next() widget.Widget { return widget.Nil() }
+
+// Disconnect implements widget.Widget.
+func (w *DiskUsage) Disconnect() {}
+
+type duContent struct {
+	node   *duNode
+	parent *duNode
+}
+
+func (c *duContent) Name() string { return c.node.name }
+
+const duBarWidth = 20
+
+// Draw the size, the percentage bar of the parent and the name.
+func (c *duContent) Draw(x, y, width int, focus bool) {
+	rate := 0.0
+	if c.parent.size > 0 {
+		rate = float64(c.node.size) / float64(c.parent.size)
+	}
+	current := int(rate * duBarWidth)
+	if current > duBarWidth {
+		current = duBarWidth
+	}
+	name := c.node.name
+	if c.node.dir {
+		name += string(filepath.Separator)
+	}
+	if c.node.err != nil {
+		name += " (" + c.node.err.Error() + ")"
+	}
+
+	style := look.Default()
+	if c.node.dir {
+		style = look.Directory()
+	}
+	if focus {
+		style = style.Reverse(true)
+	}
+	end := x + width
+	x = widget.SetCells(x, y, fmt.Sprintf("%8s %5.1f%% |", util.FormatSize(c.node.size), rate*100), style)
+	x = widget.SetCells(x, y, strings.Repeat(">", current), look.Progress())
+	x = widget.SetCells(x, y, strings.Repeat("-", duBarWidth-current)+"| ", style)
+	if end-x > 0 {
+		name = runewidth.Truncate(name, end-x, "~")
+		widget.SetCells(x, y, runewidth.FillRight(name, end-x), style)
+	}
+}
+
+// duErrors counts directories failed to read in the tree.
+func duErrors(n *duNode) int {
+	count := 0
+	if n.err != nil {
+		count++
+	}
+	for _, c := range n.children {
+		count += duErrors(c)
+	}
+	return count
+}
@@ -1,28 +1,49 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/anmitsu/goful/filer"
 	"github.com/anmitsu/goful/message"
 	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/trash"
 	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
 	"github.com/anmitsu/goful/widget"
 )
 
+// abs returns the path of the name resolved against the focused directory.
+func (g *Goful) abs(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(g.Dir().Path, name)
+}
+
 func (g *Goful) rename(src, dst string) {
-	if _, err := os.Lstat(dst); err != nil {
+	srcAbs, dstAbs := g.abs(src), g.abs(dst)
+	overwrite := false
+	if _, err := os.Lstat(dstAbs); err != nil {
 		if !os.IsNotExist(err) {
 			message.Error(err)
 			return
 		}
 	} else {
+		overwrite = true
 		message := fmt.Sprintf("Overwrite? %s", dst)
 		switch g.dialog(message, "y", "n") {
 		case "y", "Y":
@@ -31,9 +52,14 @@ func (g *Goful) rename(src, dst string) {
 			return
 		}
 	}
-	if err := os.Rename(src, dst); err != nil {
+	if err := os.Rename(srcAbs, dstAbs); err != nil {
 		message.Error(err)
 	} else {
+		g.journal.record(&journalEntry{
+			Op:           journalRename,
+			Pairs:        []journalPair{{srcAbs, dstAbs}},
+			Irreversible: overwrite,
+		})
 		message.Infof("Renamed %s -> %s", src, dst)
 	}
 }
@@ -64,18 +90,28 @@ func (g *Goful) bulkRename(pattern, repl string, files ...*filer.FileStat) {
 	switch g.dialog(fmt.Sprintf("Rename(%d)? origin -> result", count), "y", "n") {
 	case "y", "Y":
 		renames := make([]string, 0, count)
+		entry := &journalEntry{Op: journalRename, Pairs: make([]journalPair, 0, count)}
 		for i, file := range files {
 			if newnames[i] == "" {
 				continue
 			}
-			if err := os.Rename(file.Name(), newnames[i]); err != nil {
+			src := file.Path()
+			dst := filepath.Join(filepath.Dir(src), newnames[i])
+			if _, err := os.Lstat(dst); err == nil {
+				entry.Irreversible = true // overwrite an existing file
+			}
+			if err := os.Rename(src, dst); err != nil {
 				message.Error(err)
 				// error handling confirm
 			} else {
 				renames = append(renames, file.Name())
+				entry.Pairs = append(entry.Pairs, journalPair{src, dst})
 			}
 			file.ResetDisplay()
 		}
+		if len(entry.Pairs) > 0 {
+			g.journal.record(entry)
+		}
 		message.Infof(`Renamed(%d) "%s" to "%s" for %s`, count, pattern, repl, renames)
 		g.Workspace().ReloadAll()
 	default:
@@ -87,7 +123,7 @@ func (g *Goful) bulkRename(pattern, repl string, files ...*filer.FileStat) {
 
 func (g *Goful) chmod(mode os.FileMode, names ...string) {
 	for _, name := range names {
-		if err := os.Chmod(name, mode); err != nil {
+		if err := os.Chmod(g.abs(name), mode); err != nil {
 			message.Error(err)
 			return
 		}
@@ -96,7 +132,10 @@ func (g *Goful) chmod(mode os.FileMode, names ...string) {
 }
 
 func (g *Goful) touch(name string, mode os.FileMode) {
-	file, err := os.OpenFile(name, os.O_CREATE, mode)
+	path := g.abs(name)
+	_, err := os.Lstat(path)
+	created := os.IsNotExist(err)
+	file, err := os.OpenFile(path, os.O_CREATE, mode)
 	if err != nil {
 		message.Error(err)
 		return
@@ -104,103 +143,284 @@ func (g *Goful) touch(name string, mode os.FileMode) {
 	if err := file.Close(); err != nil {
 		message.Error(err)
 	}
+	if created {
+		g.journal.record(&journalEntry{Op: journalTouch, Paths: []string{path}, Mode: mode})
+	}
 	message.Infof("Touched file %s", name)
 }
 
-func (g *Goful) remove(files ...string) {
+func (g *Goful) mkdir(name string, mode os.FileMode) {
+	path := g.abs(name)
+	dirs := createdDirs(path)
+	if err := os.MkdirAll(path, mode); err != nil {
+		message.Error(err)
+		return
+	}
+	if len(dirs) > 0 {
+		g.journal.record(&journalEntry{Op: journalMkdir, Paths: dirs, Mode: mode})
+	}
+	message.Info("Made directory " + name)
+}
+
+func (g *Goful) remove(files ...string) *job {
 	filesAbs := make([]string, len(files))
 	for i := 0; i < len(files); i++ {
-		filesAbs[i], _ = filepath.Abs(files[i])
+		filesAbs[i] = g.abs(files[i])
 	}
-	go func() {
-		defer g.syncCallback(func() { g.Workspace().ReloadAll() })
+	return g.asyncFilectrl(jobRemove, "", filesAbs, func(j *job) error {
+		g.journal.record(&journalEntry{Op: journalRemove, Paths: filesAbs, Irreversible: true})
+		if err := removeFiles(j, vfs.Local, filesAbs...); err != nil {
+			return err
+		}
+		message.Infof("Removed %s", files)
+		return nil
+	})
+}
 
-		if err := removeFiles(filesAbs...); err != nil {
-			message.Error(err)
-		} else {
-			message.Infof("Removed %s", files)
+func (g *Goful) trash(files ...string) *job {
+	filesAbs := make([]string, len(files))
+	for i := 0; i < len(files); i++ {
+		filesAbs[i] = g.abs(files[i])
+	}
+	return g.asyncFilectrl(jobTrash, "", filesAbs, func(j *job) error {
+		pairs, err := trashFiles(j, filesAbs...)
+		if len(pairs) > 0 {
+			g.journal.record(&journalEntry{Op: journalTrash, Pairs: pairs})
 		}
-	}()
+		if err != nil {
+			return err
+		}
+		message.Infof("Trashed %s", files)
+		return nil
+	})
+}
+
+// RestoreTrash restores marked or the cursor trashed files to the original
+// paths, asking before overwriting existing files.
+func (g *Goful) RestoreTrash() {
+	if !g.Dir().IsTrash() {
+		message.Errorf("Not listing the trash")
+		return
+	}
+	if !g.Dir().IsMark() && g.File().Name() == ".." {
+		return
+	}
+	files := g.Dir().MarkfilePaths()
+	g.asyncFilectrl(jobRestore, "", files, func(j *job) error {
+		n, err := g.restoreFiles(j, files...)
+		if err != nil {
+			return err
+		}
+		message.Infof("Restored %d files", n)
+		return nil
+	})
+}
+
+// restoreFiles restores the trashed files and returns the number of files
+// restored except skipped by answering not to overwrite.
+func (g *Goful) restoreFiles(j *job, files ...string) (int, error) {
+	all := false
+	n := 0
+	for _, file := range files {
+		if err := j.wait(); err != nil {
+			return n, err
+		}
+		e, err := trash.Lookup(file)
+		if err != nil {
+			return n, err
+		}
+		if _, err := os.Lstat(e.Path); err == nil {
+			if !all {
+				switch g.dialog(fmt.Sprintf("Overwrite? %s", e.Path), "y", "n", "Y", "N") {
+				case "y":
+				case "Y":
+					all = true
+				case "n":
+					continue
+				case "N":
+					return n, nil
+				default:
+					j.cancel()
+					return n, j.wait()
+				}
+			}
+			if err := os.RemoveAll(e.Path); err != nil {
+				return n, err
+			}
+		}
+		if err := e.Restore(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (g *Goful) emptyTrash(entries ...*trash.Entry) {
+	files := make([]string, len(entries))
+	for i, e := range entries {
+		files[i] = e.File()
+	}
+	g.asyncFilectrl(jobRemove, "", files, func(j *job) error {
+		g.journal.record(&journalEntry{Op: journalRemove, Paths: files, Irreversible: true})
+		if err := emptyTrash(j, entries...); err != nil {
+			return err
+		}
+		message.Infof("Emptied %d files from the trash", len(entries))
+		return nil
+	})
 }
 
 func (g *Goful) copy(dst string, src ...string) {
-	srcAbs := make([]string, len(src))
-	for i := 0; i < len(src); i++ {
-		srcAbs[i], _ = filepath.Abs(src[i])
+	if g.inArchive(g.abs(dst)) {
+		return
+	}
+	if arc, dir := g.Dir().ArchiveDir(); arc != "" {
+		if names := archiveNames(arc, src); names != nil {
+			g.extract(dst, arc, dir, names)
+			return
+		}
+	}
+	srcFS, dstFS, dstAbs, srcAbs := g.walkPaths(dst, src)
+	var manifest *resumeManifest
+	if g.resumeDir != "" && vfs.IsLocal(srcFS) && vfs.IsLocal(dstFS) {
+		manifest = newResumeManifest(g.resumeDir, dstAbs, srcAbs)
 	}
-	dstAbs, _ := filepath.Abs(dst)
+	g.copyWithManifest(srcFS, dstFS, dstAbs, srcAbs, manifest)
+}
 
-	g.asyncFilectrl(func() {
-		walker := g.newWalker(overwriteNo, overwriteNo, copyJob{})
-		if err := letWalk(walker, dstAbs, srcAbs...); err != nil {
-			message.Error(err)
-		} else {
-			message.Infof("Copied to %s from %s", dstAbs, srcAbs)
+// copyWithManifest starts the copy job recording the resume manifest if not
+// nil.  The manifest is removed if the job is done or canceled, and remains if
+// failed.
+func (g *Goful) copyWithManifest(srcFS, dstFS vfs.FS, dstAbs string, srcAbs []string, manifest *resumeManifest) {
+	opts := g.copyOpts.forJob()
+
+	g.asyncFilectrl(jobCopy, dstAbs, srcAbs, func(j *job) error {
+		walker := g.newWalker(j, overwriteNo, overwriteNo, copyJob{opts, manifest, srcFS, dstFS})
+		walker.src, walker.dst = srcFS, dstFS
+		if manifest != nil {
+			if err := manifest.start(); err != nil {
+				message.Error(err)
+			}
+			if manifest.resumed {
+				walker.dirConfirmed = overwriteYesAll // merge directories copied before
+			}
+			walker.manifest = manifest
+		}
+		err := letWalk(walker, dstAbs, srcAbs...)
+		if manifest != nil && (err == nil || errors.Is(err, context.Canceled)) {
+			manifest.remove()
 		}
+		if err != nil {
+			return err
+		}
+		message.Infof("Copied to %s%s from %s", dstFS, dstAbs, srcAbs)
+		return nil
 	})
 }
 
+// move moves the files as a job.  The journal records the files moved by the
+// job, even if failed or canceled, only on the local disk.
 func (g *Goful) move(dst string, src ...string) {
-	srcAbs := make([]string, len(src))
-	for i := 0; i < len(src); i++ {
-		srcAbs[i], _ = filepath.Abs(src[i])
+	if g.inArchive(g.abs(dst)) {
+		return
 	}
-	dstAbs, _ := filepath.Abs(dst)
+	srcFS, dstFS, dstAbs, srcAbs := g.walkPaths(dst, src)
+	opts := g.copyOpts.forJob()
 
-	g.asyncFilectrl(func() {
-		walker := g.newWalker(overwriteNo, overwriteNo, moveJob{})
-		if err := letWalk(walker, dstAbs, srcAbs...); err != nil {
-			message.Error(err)
-		} else {
-			message.Infof("Moved to %s from %s", dstAbs, srcAbs)
+	g.asyncFilectrl(jobMove, dstAbs, srcAbs, func(j *job) error {
+		var entry *journalEntry
+		if vfs.IsLocal(srcFS) && vfs.IsLocal(dstFS) {
+			entry = &journalEntry{Op: journalMove}
+		}
+		walker := g.newWalker(j, overwriteNo, overwriteNo, moveJob{opts, srcFS, dstFS, entry})
+		walker.src, walker.dst = srcFS, dstFS
+		walker.moved = entry
+		err := letWalk(walker, dstAbs, srcAbs...)
+		if entry != nil && len(entry.Pairs) > 0 {
+			g.journal.record(entry)
 		}
+		if err != nil {
+			return err
+		}
+		message.Infof("Moved to %s%s from %s", dstFS, dstAbs, srcAbs)
+		return nil
 	})
 }
 
-func (g *Goful) asyncFilectrl(fn func()) {
-	go func() {
-		g.task <- 1
-		g.ResizeRelative(0, 0, 0, -2)
-		g.Next().ResizeRelative(0, -2, 0, 0)
-		defer g.syncCallback(func() {
-			g.ResizeRelative(0, 0, 0, 2)
-			g.Next().ResizeRelative(0, 2, 0, 0) // for cmdline and menu
-			widget.Show()
-			g.Workspace().ReloadAll()
-			<-g.task
-		})
-		fn()
-	}()
+// inArchive reports whether the destination is in an archive file or the next
+// directory listing an archive, and shows the error as archives are read-only.
+// Entries are extracted to the directory containing the focused archive.
+func (g *Goful) inArchive(dstAbs string) bool {
+	next := g.Workspace().NextDir()
+	for _, d := range []*filer.Directory{next, g.Dir()} {
+		arc, _ := d.ArchiveDir()
+		if arc != "" && (d == next && d != g.Dir() && dstAbs == d.Path || util.IsSubpath(arc, dstAbs)) {
+			message.Errorf("Read-only archive %s", arc)
+			return true
+		}
+	}
+	return false
+}
+
+// walkPaths returns the file systems and the absolute paths of the
+// destination and the sources in the focused directory.  The destination is on
+// the file system of the next directory containing it, or of the focused
+// directory containing it, or on the local disk.
+func (g *Goful) walkPaths(dst string, src []string) (vfs.FS, vfs.FS, string, []string) {
+	dir := g.Dir()
+	dstAbs := g.abs(dst)
+	dstFS := vfs.Local
+	if !filepath.IsAbs(dst) {
+		dstFS = dir.FS()
+	} else {
+		for _, d := range []*filer.Directory{g.Workspace().NextDir(), dir} {
+			if util.IsSubpath(d.Path, dstAbs) {
+				dstFS = d.FS()
+				break
+			}
+		}
+	}
+	srcAbs := make([]string, len(src))
+	for i, s := range src {
+		srcAbs[i] = g.abs(s)
+	}
+	return dir.FS(), dstFS, dstAbs, srcAbs
 }
 
 func letWalk(walker *walker, dst string, src ...string) error {
-	size, count := util.CalcSizeCount(src...)
-	progress.Start(float64(size))
-	progress.StartTaskCount(count)
-	var err error
+	size, count := vfs.SizeCount(walker.src, src...)
+	atomic.StoreInt64(&walker.job.total, size)
+	walker.job.progress = progress.Start(float64(size), count)
+	defer walker.job.progress.Finish()
 	for _, s := range src {
-		if e := walker.walk(s, dst); e != nil {
-			err = e
-			break
+		if err := walker.walk(s, dst); err != nil {
+			return err
 		}
 	}
-	progress.Finish()
-	return err
+	return nil
 }
 
 type walker struct {
 	*Goful
+	job           *job
 	fileConfirmed overWrite
 	dirConfirmed  overWrite
 	callback      fileJob
+	manifest      *resumeManifest // resume manifest of the copy job or nil
+	src, dst      vfs.FS          // file systems of sources and destinations
+	moved         *journalEntry   // journal entry of the move job or nil
 }
 
-func (g *Goful) newWalker(fileConfirmed, dirConfirmed overWrite, f fileJob) *walker {
-	return &walker{g, fileConfirmed, dirConfirmed, f}
+func (g *Goful) newWalker(j *job, fileConfirmed, dirConfirmed overWrite, f fileJob) *walker {
+	return &walker{g, j, fileConfirmed, dirConfirmed, f, nil, vfs.Local, vfs.Local, nil}
 }
 
 func (w *walker) walk(src, dst string) error {
-	if dststat, err := os.Stat(dst); err != nil {
+	if err := w.job.wait(); err != nil {
+		return err
+	}
+	if dststat, err := w.dst.Stat(dst); err != nil {
 		if !os.IsNotExist(err) { // ignore error if not exist dst and create dst
 			return err
 		}
@@ -209,23 +429,44 @@ func (w *walker) walk(src, dst string) error {
 			dst = filepath.Join(dst, filepath.Base(src))
 		}
 	}
-	srcstat, err := os.Lstat(src)
+	srcstat, err := w.src.Lstat(src)
 	if err != nil {
 		return err
 	}
+	_, err = w.dst.Lstat(dst)
+	existed := err == nil
 	if srcstat.IsDir() {
-		if strings.HasPrefix(dst, src) {
+		if w.src == w.dst && util.IsSubpath(src, dst) {
 			return fmt.Errorf("cannot copy/move directory %s into itself %s", src, dst)
 		}
-		if err := w.dir2dir(src, dst); err != nil {
-			return err
-		}
+		err = w.dir2dir(src, dst)
 	} else {
-		if err := w.file2file(src, dst); err != nil {
-			return err
-		}
+		var written string
+		written, err = w.file2file(src, dst)
+		existed = existed && written == dst
+		dst = written
 	}
-	return nil
+	if w.moved != nil && dst != "" {
+		w.recordMove(src, dst, existed)
+	}
+	return err
+}
+
+// recordMove adds the moved path to the journal entry.  The entry is
+// irreversible if the move overwrites or merges into the existing path, or
+// leaves files in the source skipped or failed.
+func (w *walker) recordMove(src, dst string, existed bool) {
+	if _, err := w.src.Lstat(src); err == nil {
+		w.moved.Irreversible = true
+		return
+	}
+	if _, err := w.dst.Lstat(dst); err != nil {
+		return
+	}
+	if existed {
+		w.moved.Irreversible = true
+	}
+	w.moved.Pairs = append(w.moved.Pairs, journalPair{src, dst})
 }
 
 type overWrite int
@@ -236,8 +477,22 @@ const (
 	overwriteYesAll
 	overwriteNoAll
 	overwriteCancel
+	overwriteKeepBoth    // write to a suffixed name
+	overwriteKeepBothAll // write to suffixed names for all
+	overwriteNewer       // overwrite all if the source is newer
+	overwriteSizeDiffer  // overwrite all if the size differs
+	overwriteRename      // write to an input name
 )
 
+// isSticky reports whether the choice applies to all following conflicts.
+func (o overWrite) isSticky() bool {
+	switch o {
+	case overwriteYesAll, overwriteNoAll, overwriteKeepBothAll, overwriteNewer, overwriteSizeDiffer:
+		return true
+	}
+	return false
+}
+
 func (w *walker) confirm(message string) overWrite {
 	switch w.dialog(message, "y", "n", "Y", "N") {
 	case "y":
@@ -253,38 +508,141 @@ func (w *walker) confirm(message string) overWrite {
 	}
 }
 
-func (w *walker) file2file(src, dst string) error {
-	if _, err := os.Lstat(dst); err != nil {
-		if !os.IsNotExist(err) {
-			return err
+const conflictTimeFormat = "06-01-02 15:04"
+
+// resolveConflict returns the path to write the source file if the destination
+// exists, or "" to skip writing.  The choice is asked in the dialog showing
+// sizes and modification times of both files unless the policy is sticky, and
+// stored to the policy.
+func (g *Goful) resolveConflict(j *job, policy *overWrite, src os.FileInfo, dst string) (string, error) {
+	return g.resolveConflictFS(j, policy, src, vfs.Local, dst)
+}
+
+// resolveConflictFS is resolveConflict for the destination on the file system.
+func (g *Goful) resolveConflictFS(j *job, policy *overWrite, src os.FileInfo, fsys vfs.FS, dst string) (string, error) {
+	dststat, err := fsys.Lstat(dst)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return dst, nil
 		}
-	} else {
-		switch w.fileConfirmed {
-		case overwriteNoAll:
-			return nil
-		case overwriteYesAll:
-			break
+		return "", err
+	}
+	if !policy.isSticky() {
+		msg := fmt.Sprintf("Overwrite? exists %s (new %sB %s, old %sB %s)", dst,
+			util.FormatSize(src.Size()), src.ModTime().Format(conflictTimeFormat),
+			util.FormatSize(dststat.Size()), dststat.ModTime().Format(conflictTimeFormat))
+		switch g.dialog(msg, "y", "n", "Y", "N", "b", "B", "u", "s", "r") {
+		case "y":
+			*policy = overwriteYes
+		case "n":
+			*policy = overwriteNo
+		case "Y":
+			*policy = overwriteYesAll
+		case "N":
+			*policy = overwriteNoAll
+		case "b":
+			*policy = overwriteKeepBoth
+		case "B":
+			*policy = overwriteKeepBothAll
+		case "u":
+			*policy = overwriteNewer
+		case "s":
+			*policy = overwriteSizeDiffer
+		case "r":
+			*policy = overwriteRename
 		default:
-			w.fileConfirmed = w.confirm(fmt.Sprintf("Overwrite? exists %s", dst))
-			switch w.fileConfirmed {
-			case overwriteNo, overwriteNoAll:
-				return nil
-			case overwriteCancel:
-				return fmt.Errorf("canceled file operation")
+			*policy = overwriteCancel
+		}
+	}
+	switch *policy {
+	case overwriteNo, overwriteNoAll:
+		return "", nil
+	case overwriteKeepBoth, overwriteKeepBothAll:
+		return suffixedPathFS(fsys, dst), nil
+	case overwriteNewer:
+		if !src.ModTime().After(dststat.ModTime()) {
+			return "", nil
+		}
+	case overwriteSizeDiffer:
+		if src.Size() == dststat.Size() {
+			return "", nil
+		}
+	case overwriteRename:
+		name := g.inputDialog("Rename to: ", filepath.Base(suffixedPathFS(fsys, dst)))
+		if name == "" {
+			return "", nil
+		}
+		return g.resolveConflictFS(j, policy, src, fsys, filepath.Join(filepath.Dir(dst), name))
+	case overwriteCancel:
+		j.cancel()
+		return "", j.wait()
+	}
+	return dst, nil
+}
+
+// suffixedPath returns a non-existent path suffixed by a number before the
+// extension such as "name_2.txt" and "name_2.tar.gz".
+func suffixedPath(path string) string {
+	return suffixedPathFS(vfs.Local, path)
+}
+
+func suffixedPathFS(fsys vfs.FS, path string) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	name := strings.TrimSuffix(base, ext)
+	if e := filepath.Ext(name); e == ".tar" && e != name { // such as "name.tar.gz"
+		ext = e + ext
+		name = strings.TrimSuffix(name, e)
+	}
+	for i := 2; ; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("%s_%d%s", name, i, ext))
+		if _, err := fsys.Lstat(p); os.IsNotExist(err) {
+			return p
+		}
+	}
+}
+
+// file2file writes the source file to the destination resolving the conflict,
+// and returns the written path or "" if skipped.
+func (w *walker) file2file(src, dst string) (string, error) {
+	srcstat, err := w.src.Lstat(src)
+	if err != nil {
+		return "", err
+	}
+	if w.manifest != nil {
+		if w.manifest.copied(srcstat, src, dst) {
+			if srcstat.Mode().IsRegular() {
+				w.job.progress.Update(float64(srcstat.Size()))
+				atomic.AddInt64(&w.job.done, srcstat.Size())
 			}
+			return dst, nil
+		}
+		if w.manifest.isPartial(dst) { // continue without confirming
+			return dst, w.callback.job(w.job, src, dst)
 		}
 	}
+	dst, err = w.resolveConflictFS(w.job, &w.fileConfirmed, srcstat, w.dst, dst)
+	if err != nil || dst == "" {
+		return "", err
+	}
 
-	if err := w.callback.job(src, dst); err != nil {
-		return err
+	if err := w.callback.job(w.job, src, dst); err != nil {
+		return "", err
 	}
-	return nil
+	return dst, nil
 }
 
 func (w *walker) dir2dir(src, dst string) error {
-	if _, err := os.Stat(dst); err != nil {
+	srcstat, err := w.src.Stat(src) // before reading to keep the access time
+	if err != nil {
+		return err
+	}
+	if _, err := w.dst.Stat(dst); err != nil {
 		if os.IsNotExist(err) { // make dst directory if dst not exists
-			if err := copyDir(src, dst); err != nil {
+			if err := w.dst.Mkdir(dst, srcstat.Mode()); err != nil {
 				return err
 			}
 		} else {
@@ -302,207 +660,442 @@ func (w *walker) dir2dir(src, dst string) error {
 			case overwriteNo, overwriteNoAll:
 				return nil
 			case overwriteCancel:
-				return fmt.Errorf("canceled file operation")
+				w.job.cancel()
+				return w.job.wait()
 			}
 		}
 	}
 
-	srcdir, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcdir.Close()
-	for {
-		fi, err := srcdir.Readdir(100)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
+	var walkErr error
+	err = w.src.ReadDir(src, func(f os.FileInfo) bool {
+		if walkErr = w.job.wait(); walkErr != nil {
+			return false
 		}
-		for _, f := range fi {
-			src := filepath.Join(src, f.Name())
-			dst := filepath.Join(dst, f.Name())
-			if f.IsDir() {
-				if err := w.dir2dir(src, dst); err != nil {
-					return err
-				}
-			} else {
-				if err := w.file2file(src, dst); err != nil {
-					return err
-				}
-			}
+		src := filepath.Join(src, f.Name())
+		dst := filepath.Join(dst, f.Name())
+		if f.IsDir() {
+			walkErr = w.dir2dir(src, dst)
+		} else {
+			_, walkErr = w.file2file(src, dst)
 		}
+		return walkErr == nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	if err != nil {
+		return err
 	}
 
-	if err := w.callback.afterVisitDir(src, dst); err != nil {
+	if err := w.callback.afterVisitDir(srcstat, src, dst); err != nil {
 		return err
 	}
 	return nil
 }
 
 type fileJob interface {
-	job(src, dst string) error
-	afterVisitDir(src, dst string) error
+	job(j *job, src, dst string) error
+	afterVisitDir(srcstat os.FileInfo, src, dst string) error
 }
 
 type (
-	copyJob struct{}
-	moveJob struct{}
+	copyJob struct {
+		opts     copyOptions
+		manifest *resumeManifest
+		src, dst vfs.FS
+	}
+	moveJob struct {
+		opts     copyOptions
+		src, dst vfs.FS
+		moved    *journalEntry // journal entry of the job or nil
+	}
 )
 
-func (job copyJob) job(src, dst string) error {
-	if err := copyFile(src, dst); err != nil {
+// copyOptions are options for copying file contents.
+type copyOptions struct {
+	verify  func() hash.Hash   // hash to verify copied files, nil is not verifying
+	archive bool               // preserve ownership, xattrs and hard links
+	links   map[linkKey]string // destinations of hard linked sources in the job
+	bufsize int                // buffer size to read and write
+}
+
+const defaultCopyBuffer = 128 * 1024
+
+// SetCopyBuffer sets the buffer size for copying file contents by reading and
+// writing, used if reflinks and copy_file_range are not supported.
+func (g *Goful) SetCopyBuffer(size int) {
+	g.copyOpts.bufsize = size
+}
+
+// forJob returns the options for a new job.
+func (o copyOptions) forJob() copyOptions {
+	if o.archive {
+		o.links = map[linkKey]string{}
+	}
+	return o
+}
+
+// SetCopyArchive sets the archive mode like `cp -a` to preserve ownership if
+// running as root, extended attributes, and hard links between copied files.
+func (g *Goful) SetCopyArchive(archive bool) {
+	g.copyOpts.archive = archive
+}
+
+var verifyHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"md5":    md5.New,
+	"crc32":  func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+}
+
+// SetCopyVerify sets the hash to verify copied files by comparing the hash of
+// the source computed while copying with the hash of the destination re-read
+// after copying.  The hash is "sha256", "md5", "crc32" (faster) or "" as not
+// verifying.
+func (g *Goful) SetCopyVerify(name string) {
+	if name == "" {
+		g.copyOpts.verify = nil
+		return
+	}
+	verify, ok := verifyHashes[name]
+	if !ok {
+		message.Errorf("Unknown verify hash %s", name)
+		return
+	}
+	g.copyOpts.verify = verify
+}
+
+func (c copyJob) job(j *job, src, dst string) error {
+	var offset int64
+	if c.manifest != nil {
+		if srcstat, err := c.src.Lstat(src); err == nil {
+			offset = c.manifest.partialOffset(srcstat, dst)
+			c.manifest.record(srcstat, dst)
+		}
+	}
+	if err := copyFile(j, c.src, c.dst, src, dst, c.opts, offset); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (job copyJob) afterVisitDir(src, dst string) error {
-	if err := copyTimes(src, dst); err != nil {
+func (c copyJob) afterVisitDir(srcstat os.FileInfo, src, dst string) error {
+	if c.opts.archive && vfs.IsLocal(c.src) && vfs.IsLocal(c.dst) {
+		if err := copyAttrs(srcstat, src, dst); err != nil {
+			return err
+		}
+	}
+	if err := copyTimes(c.dst, srcstat, dst); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (job moveJob) job(src, dst string) error {
-	if err := moveFile(src, dst); err != nil {
+// job renames the file on the same file system, or copies and removes it.
+// Moves by copying are irreversible because undo only renames.
+func (m moveJob) job(j *job, src, dst string) error {
+	if m.src == m.dst {
+		if err := m.src.Rename(src, dst); err == nil {
+			return nil
+		}
+	}
+	if m.moved != nil {
+		m.moved.Irreversible = true
+	}
+	if err := copyFileAfterRemove(j, m.src, m.dst, src, dst, m.opts); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (job moveJob) afterVisitDir(src, dst string) error {
-	if err := copyTimes(src, dst); err != nil {
+func (m moveJob) afterVisitDir(srcstat os.FileInfo, src, dst string) error {
+	if m.opts.archive && vfs.IsLocal(m.src) && vfs.IsLocal(m.dst) {
+		if err := copyAttrs(srcstat, src, dst); err != nil {
+			return err
+		}
+	}
+	if err := copyTimes(m.dst, srcstat, dst); err != nil {
 		return err
 	}
-	if err := removeEmptyDir(src); err != nil {
+	if err := removeEmptyDir(m.src, src); err != nil {
 		return err
 	}
 	return nil
 }
 
-func removeFiles(files ...string) error {
+func removeFiles(j *job, fsys vfs.FS, files ...string) error {
+	size, count := vfs.SizeCount(fsys, files...)
+	atomic.StoreInt64(&j.total, size)
+	j.progress = progress.Start(float64(size), count)
+	defer j.progress.Finish()
 	for _, file := range files {
-		if err := os.RemoveAll(file); err != nil {
+		if err := removeAll(j, fsys, file); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func copyDir(src, dst string) error {
-	srcstat, err := os.Stat(src)
+// removeAll removes a path and any children it contains like os.RemoveAll,
+// but checks the job context for each file.
+func removeAll(j *job, fsys vfs.FS, path string) error {
+	if err := j.wait(); err != nil {
+		return err
+	}
+	lstat, err := fsys.Lstat(path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return err
 	}
-	if err := os.Mkdir(dst, srcstat.Mode()); err != nil {
+	if lstat.IsDir() {
+		names := []string{}
+		if err := fsys.ReadDir(path, func(fi os.FileInfo) bool {
+			names = append(names, fi.Name())
+			return true
+		}); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := removeAll(j, fsys, filepath.Join(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := fsys.Remove(path); err != nil {
 		return err
 	}
+	if !lstat.IsDir() && lstat.Mode()&os.ModeSymlink == 0 {
+		j.progress.StartTask(lstat)
+		j.progress.Update(float64(lstat.Size()))
+		j.progress.FinishTask()
+		atomic.AddInt64(&j.done, lstat.Size())
+	}
+	return nil
+}
+
+// trashFiles moves the files to the trash and returns pairs of the file and the
+// trashed path for the journal.
+func trashFiles(j *job, files ...string) ([]journalPair, error) {
+	pairs := make([]journalPair, 0, len(files))
+	size, _ := vfs.SizeCount(vfs.Local, files...)
+	atomic.StoreInt64(&j.total, size)
+	j.progress = progress.Start(float64(size), len(files))
+	defer j.progress.Finish()
+	for _, file := range files {
+		if err := j.wait(); err != nil {
+			return pairs, err
+		}
+		lstat, err := vfs.Local.Lstat(file)
+		if err != nil {
+			return pairs, err
+		}
+		size, _ := vfs.SizeCount(vfs.Local, file)
+		j.progress.StartTask(lstat)
+		trashed, err := trash.Move(file)
+		if err != nil {
+			return pairs, err
+		}
+		pairs = append(pairs, journalPair{file, trashed})
+		j.progress.Update(float64(size))
+		j.progress.FinishTask()
+		atomic.AddInt64(&j.done, size)
+	}
+	return pairs, nil
+}
+
+func emptyTrash(j *job, entries ...*trash.Entry) error {
+	files := make([]string, len(entries))
+	for i, e := range entries {
+		files[i] = e.File()
+	}
+	size, count := vfs.SizeCount(vfs.Local, files...)
+	atomic.StoreInt64(&j.total, size)
+	j.progress = progress.Start(float64(size), count)
+	defer j.progress.Finish()
+	for _, e := range entries {
+		if err := removeAll(j, vfs.Local, e.File()); err != nil {
+			return err
+		}
+		if err := vfs.Local.Remove(e.InfoFile()); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func copyFile(src, dst string) error { // not make directories in this function
+// copyFile copies the file between the file systems continuing from the offset
+// of the partial destination on the local disk.  Symlinks are copied if both
+// file systems support them, or followed.
+func copyFile(j *job, srcFS, dstFS vfs.FS, src, dst string, opts copyOptions, offset int64) error { // not make directories in this function
+	local := vfs.IsLocal(srcFS) && vfs.IsLocal(dstFS)
 	// copy symlink
-	if lstat, err := os.Lstat(src); err != nil {
+	if lstat, err := srcFS.Lstat(src); err != nil {
 		return err
 	} else if lstat.Mode()&os.ModeSymlink != 0 {
-		if err := copySymlink(src, dst); err != nil {
+		if ok, err := copySymlink(srcFS, dstFS, src, dst); err != nil {
 			return err
+		} else if ok {
+			if opts.archive && local && os.Geteuid() == 0 {
+				return chownLike(lstat, dst)
+			}
+			return nil
 		}
-		return nil
 	}
 
-	srcfile, err := os.Open(src)
+	srcstat, err := srcFS.Stat(src)
 	if err != nil {
 		return err
 	}
-	defer srcfile.Close()
-
-	srcstat, err := srcfile.Stat()
-	if err != nil {
-		return err
+	if !srcstat.Mode().IsRegular() && !local {
+		return fmt.Errorf("cannot copy %s: not a regular file", src)
 	}
-	dstfile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcstat.Mode().Perm())
+	srcfile, err := srcFS.Open(src)
 	if err != nil {
 		return err
 	}
-	defer dstfile.Close()
+	defer srcfile.Close()
 
-	if err := letCopy(srcfile, dstfile); err != nil {
-		return err
+	if opts.links != nil && local {
+		if key, nlink, ok := fileLinkKey(srcstat); ok && nlink > 1 {
+			if first, ok := opts.links[key]; ok && os.Link(first, dst) == nil {
+				j.progress.Update(float64(srcstat.Size()))
+				atomic.AddInt64(&j.done, srcstat.Size())
+				return nil
+			}
+			defer func() {
+				if _, err := os.Lstat(dst); err == nil {
+					opts.links[key] = dst
+				}
+			}()
+		}
+	}
+	var dstfile io.WriteCloser
+	if offset > 0 && local {
+		dstfile, err = os.OpenFile(dst, os.O_WRONLY, srcstat.Mode().Perm())
+	} else {
+		offset = 0
+		dstfile, err = dstFS.Create(dst, srcstat.Mode().Perm())
 	}
-	if err := copyTimes(src, dst); err != nil {
+	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func copySymlink(src, dst string) error {
-	linksrc, err := os.Readlink(src) // not eval link path
+	var h hash.Hash
+	if opts.verify != nil {
+		h = opts.verify()
+	}
+	sf, srcOK := srcfile.(*os.File)
+	df, dstOK := dstfile.(*os.File)
+	if offset > 0 {
+		if err := seekPartial(j, sf, df, offset, h); err != nil {
+			dstfile.Close()
+			return err
+		}
+	}
+	if srcOK && dstOK {
+		err = letCopy(j, sf, df, h, opts.bufsize, offset)
+	} else {
+		var w io.Writer = dstfile
+		if h != nil {
+			w = io.MultiWriter(dstfile, h)
+		}
+		_, err = copyProgress(j, srcstat, w, srcfile)
+	}
 	if err != nil {
+		dstfile.Close()
+		_ = dstFS.Remove(dst) // not leave a half-written file
 		return err
 	}
-	if err := os.Symlink(linksrc, dst); err != nil {
+	if h != nil && dstOK {
+		if err := df.Sync(); err != nil {
+			dstfile.Close()
+			return err
+		}
+	}
+	if err := dstfile.Close(); err != nil {
+		return err
+	}
+	if h != nil {
+		if err := verifyFile(j, dstFS, dst, opts.verify(), h.Sum(nil)); err != nil {
+			_ = dstFS.Remove(dst) // not leave a corrupted file
+			return err
+		}
+	}
+	if opts.archive && local {
+		if err := copyAttrs(srcstat, src, dst); err != nil {
+			return err
+		}
+	}
+	if err := copyTimes(dstFS, srcstat, dst); err != nil {
 		return err
 	}
 	return nil
 }
 
-func copyTimes(src, dst string) error {
-	srcstat, err := os.Stat(src)
+// copySymlink copies the symlink and reports whether copied, or false if
+// either file system does not support symlinks.
+func copySymlink(srcFS, dstFS vfs.FS, src, dst string) (bool, error) {
+	srcLinker, ok := srcFS.(vfs.Linker)
+	if !ok {
+		return false, nil
+	}
+	dstLinker, ok := dstFS.(vfs.Linker)
+	if !ok {
+		return false, nil
+	}
+	linksrc, err := srcLinker.Readlink(src) // not eval link path
 	if err != nil {
-		return err
+		return false, err
 	}
-	mtime := srcstat.ModTime()
-	atime := srcstat.ModTime() // atime := time.Unix(srcstat.Sys().(*syscall.Stat_t).Atim.Unix())
-	if err := os.Chtimes(dst, atime, mtime); err != nil {
-		return err
+	if err := dstLinker.Symlink(linksrc, dst); err != nil {
+		return false, err
 	}
-	return nil
+	return true, nil
 }
 
-func moveFile(src, dst string) error {
-	if err := os.Rename(src, dst); err != nil {
-		if err := copyFileAfterRemove(src, dst); err != nil {
-			return err
-		}
+// copyTimes sets the access and modification times of the source stat taken
+// before reading the source.
+func copyTimes(fsys vfs.FS, srcstat os.FileInfo, dst string) error {
+	mtime := srcstat.ModTime()
+	atime := fileAtime(srcstat)
+	if err := fsys.Chtimes(dst, atime, mtime); err != nil {
+		return err
 	}
 	return nil
 }
 
-func copyFileAfterRemove(src, dst string) error {
-	if err := copyFile(src, dst); err != nil {
+func copyFileAfterRemove(j *job, srcFS, dstFS vfs.FS, src, dst string, opts copyOptions) error {
+	if err := copyFile(j, srcFS, dstFS, src, dst, opts, 0); err != nil {
 		return err
 	}
-	if err := os.Remove(src); err != nil {
+	if err := srcFS.Remove(src); err != nil {
 		return err
 	}
 	return nil
 }
 
-func removeEmptyDir(src string) error {
-	srcdir, err := os.Open(src)
-	if err != nil {
+func removeEmptyDir(fsys vfs.FS, src string) error {
+	empty := true
+	if err := fsys.ReadDir(src, func(os.FileInfo) bool {
+		empty = false
+		return false
+	}); err != nil {
 		return err
 	}
-	defer srcdir.Close()
-
-	remain, err := srcdir.Readdir(1)
-	if err != nil && err != io.EOF {
-		return err
-	}
-	if len(remain) < 1 {
-		if err := os.Remove(src); err != nil {
+	if empty {
+		if err := fsys.Remove(src); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func letCopy(srcfile, dstfile *os.File) error {
+// drawProgress draws the progress periodically until calling the returned
+// function.
+func drawProgress() func() {
 	quit := make(chan bool)
-	defer close(quit)
-	go func() { // drawing progress
+	go func() {
 		ticker := time.NewTicker(50 * time.Millisecond)
 		defer ticker.Stop()
 		for {
@@ -515,15 +1108,37 @@ func letCopy(srcfile, dstfile *os.File) error {
 			}
 		}
 	}()
+	return func() { close(quit) }
+}
+
+// letCopy copies the file contents and writes them to the hash if not nil.
+// Reflinks and copy_file_range are used if supported and not hashing, and
+// holes of sparse files are kept.
+func letCopy(j *job, srcfile, dstfile *os.File, h hash.Hash, bufsize int, offset int64) error {
+	defer drawProgress()()
 
 	srcstat, err := srcfile.Stat()
 	if err != nil {
 		return err
 	}
-	progress.StartTask(srcstat)
-	defer progress.FinishTask()
-	buf := make([]byte, 4096)
+	j.progress.StartTask(srcstat)
+	defer j.progress.FinishTask()
+	if h == nil {
+		if ok, err := fastCopy(j, srcfile, dstfile, offset, srcstat.Size()); ok || err != nil {
+			return err
+		}
+	}
+
+	sparse := isSparse(srcstat)
+	if bufsize <= 0 {
+		bufsize = defaultCopyBuffer
+	}
+	buf := make([]byte, bufsize)
+	size := offset
 	for {
+		if err := j.wait(); err != nil {
+			return err
+		}
 		n, err := srcfile.Read(buf)
 		if err != nil && err != io.EOF {
 			return err
@@ -531,10 +1146,95 @@ func letCopy(srcfile, dstfile *os.File) error {
 		if n == 0 {
 			break
 		}
-		if _, err := dstfile.Write(buf[:n]); err != nil {
+		if sparse && isZero(buf[:n]) { // make a hole
+			if _, err := dstfile.Seek(int64(n), io.SeekCurrent); err != nil {
+				return err
+			}
+		} else if _, err := dstfile.Write(buf[:n]); err != nil {
+			return err
+		}
+		if h != nil {
+			h.Write(buf[:n])
+		}
+		size += int64(n)
+		j.progress.Update(float64(n))
+		atomic.AddInt64(&j.done, int64(n))
+	}
+	if sparse { // extend the trailing hole
+		return dstfile.Truncate(size)
+	}
+	return nil
+}
+
+// seekPartial seeks the files to the offset to continue copying, and writes
+// the copied part of the source to the hash if not nil.
+func seekPartial(j *job, srcfile, dstfile *os.File, offset int64, h hash.Hash) error {
+	if h != nil {
+		if _, err := io.Copy(h, io.NewSectionReader(srcfile, 0, offset)); err != nil {
+			return err
+		}
+	}
+	if _, err := srcfile.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := dstfile.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	j.progress.Update(float64(offset))
+	atomic.AddInt64(&j.done, offset)
+	return nil
+}
+
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyFile re-reads the copied file dropping the page cache if possible, and
+// compares the hash with the sum of the source.
+func verifyFile(j *job, fsys vfs.FS, path string, h hash.Hash, sum []byte) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if f, ok := file.(*os.File); ok {
+		dropCache(f)
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		if err := j.wait(); err != nil {
+			return err
+		}
+		n, err := file.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		} else if err != nil {
 			return err
 		}
-		progress.Update(float64(n))
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return fmt.Errorf("verify %s: checksum mismatch", path)
 	}
 	return nil
 }
+
+// copyAttrs copies the ownership if running as root, extended attributes and
+// the special mode bits such as setuid.
+func copyAttrs(srcstat os.FileInfo, src, dst string) error {
+	if os.Geteuid() == 0 {
+		if err := chownLike(srcstat, dst); err != nil {
+			return err
+		}
+	}
+	if err := copyXattrs(src, dst); err != nil {
+		message.Error(err) // not fail the job like `cp -a`
+	}
+	mode := srcstat.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	return os.Chmod(dst, mode) // after chown clearing setuid bits
+}
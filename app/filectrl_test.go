@@ -0,0 +1,386 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anmitsu/goful/filer"
+	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/trash"
+	"github.com/anmitsu/goful/vfs"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestSuffixedPath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "a_2.txt", ".rc"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		in  string
+		out string
+	}{
+		{"a.txt", "a_3.txt"},
+		{".rc", ".rc_2"},
+		{"dir", "dir_2"},
+		{"a.tar.gz", "a_2.tar.gz"},
+	} {
+		if out := suffixedPath(filepath.Join(dir, c.in)); out != filepath.Join(dir, c.out) {
+			t.Errorf("suffixedPath(%q)=%q, want %q", c.in, out, c.out)
+		}
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	j := newJob(jobCopy, path, []string{path}, nil)
+	for name, newHash := range verifyHashes {
+		h := newHash()
+		h.Write([]byte("content"))
+		if err := verifyFile(j, vfs.Local, path, newHash(), h.Sum(nil)); err != nil {
+			t.Errorf("%s: verifyFile()=%v, want nil", name, err)
+		}
+		h = newHash()
+		h.Write([]byte("corrupt"))
+		if err := verifyFile(j, vfs.Local, path, newHash(), h.Sum(nil)); err == nil {
+			t.Errorf("%s: verifyFile() for mismatch returns nil", name)
+		}
+	}
+}
+
+func TestCopyTimes(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("access time is not supported")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	for _, path := range []string{src, dst} {
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	atime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	mtime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.Local)
+	if err := os.Chtimes(src, atime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	srcstat, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copyTimes(vfs.Local, srcstat, dst); err != nil {
+		t.Fatal(err)
+	}
+	dststat, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileAtime(dststat).Equal(atime) || !dststat.ModTime().Equal(mtime) {
+		t.Errorf("copied times %v %v, want %v %v", fileAtime(dststat), dststat.ModTime(), atime, mtime)
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	if !isZero(make([]byte, 10)) || isZero([]byte{0, 0, 1}) || !isZero(nil) {
+		t.Error("isZero returns wrong results")
+	}
+}
+
+func TestFastCopySparse(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	const size = 4 << 20
+	srcfile, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcfile.Close()
+	if _, err := srcfile.WriteAt([]byte("data"), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcfile.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	dstfile, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstfile.Close()
+
+	j := newJob(jobCopy, dst, []string{src}, nil)
+	j.progress = progress.Start(size, 1)
+	defer j.progress.Finish()
+	ok, err := fastCopy(j, srcfile, dstfile, 0, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		if done := atomic.LoadInt64(&j.done); done != 0 {
+			t.Errorf("progressed %d bytes falling back", done)
+		}
+		t.Skip("fast copy is not supported")
+	}
+	if done := atomic.LoadInt64(&j.done); done != size {
+		t.Errorf("progressed %d bytes, want %d", done, size)
+	}
+	data, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, _ := ioutil.ReadFile(src)
+	if !bytes.Equal(data, expect) {
+		t.Errorf("copied contents differ")
+	}
+	srcstat, _ := srcfile.Stat()
+	dststat, _ := dstfile.Stat()
+	if isSparse(srcstat) && !isSparse(dststat) {
+		t.Errorf("holes of %s are not kept", src)
+	}
+}
+
+func TestStreamWalk(t *testing.T) {
+	mem := vfs.NewMem()
+	if err := mem.WriteFile("/src/a.txt", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.WriteFile("/src/sub/b.txt", []byte("world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	g := &Goful{}
+
+	// copy from the memory to the local disk
+	j := newJob(jobCopy, dir, []string{"/src"}, nil)
+	w := g.newWalker(j, overwriteNo, overwriteNo, copyJob{copyOptions{}, nil, mem, vfs.Local})
+	w.src = mem
+	if err := letWalk(w, dir, "/src"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "src", "sub", "b.txt")); err != nil || string(data) != "world" {
+		t.Errorf("copied %q, %v", data, err)
+	}
+	if done := atomic.LoadInt64(&j.done); done != 10 {
+		t.Errorf("progressed %d bytes, want 10", done)
+	}
+
+	// move back into the memory
+	if err := mem.Mkdir("/dst", 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "src")
+	j = newJob(jobMove, "/dst", []string{src}, nil)
+	w = g.newWalker(j, overwriteNo, overwriteNo, moveJob{copyOptions{}, vfs.Local, mem, nil})
+	w.dst = mem
+	if err := letWalk(w, "/dst", src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(src); !os.IsNotExist(err) {
+		t.Errorf("%s remains after moving", src)
+	}
+	if fi, err := mem.Stat("/dst/src/sub/b.txt"); err != nil || fi.Size() != 5 || fi.Mode().Perm() != 0600 {
+		t.Errorf("moved %v, %v", fi, err)
+	}
+}
+
+// dialogKeys sends key events to dialogs.  "C-m" enters, "C-u" clears the
+// text and others are typed.
+func dialogKeys(g *Goful, keys ...string) {
+	go func() {
+		for _, key := range keys {
+			switch key {
+			case "C-m":
+				g.event <- tcell.NewEventKey(tcell.KeyCtrlM, 0, tcell.ModNone)
+			case "C-u":
+				g.event <- tcell.NewEventKey(tcell.KeyCtrlU, 0, tcell.ModNone)
+			default:
+				for _, r := range key {
+					g.event <- tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+				}
+			}
+		}
+	}()
+}
+
+func TestResolveConflictFS(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	mem := vfs.NewMem()
+	files := []struct {
+		path    string
+		data    string
+		modTime time.Time
+	}{
+		{"/dst/a.txt", "hello", old},
+		{"/src/newer", "hello", old.Add(time.Hour)},
+		{"/src/older", "world", old.Add(-time.Hour)},
+		{"/src/bigger", "hello world", old},
+	}
+	for _, f := range files {
+		if err := mem.WriteFile(f.path, []byte(f.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.Chtimes(f.path, f.modTime, f.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conflicts := []struct {
+		policy overWrite
+		src    string
+		keys   []string // answers to the dialogs
+		dst    string   // "" is skipped
+		after  overWrite
+	}{
+		{overwriteNewer, "/src/newer", nil, "/dst/a.txt", overwriteNewer},
+		{overwriteNewer, "/src/older", nil, "", overwriteNewer},
+		{overwriteSizeDiffer, "/src/bigger", nil, "/dst/a.txt", overwriteSizeDiffer},
+		{overwriteSizeDiffer, "/src/newer", nil, "", overwriteSizeDiffer},
+		{overwriteYesAll, "/src/older", nil, "/dst/a.txt", overwriteYesAll},
+		{overwriteNoAll, "/src/newer", nil, "", overwriteNoAll},
+		{overwriteKeepBothAll, "/src/newer", nil, "/dst/a_2.txt", overwriteKeepBothAll},
+		{overwriteYes, "/src/older", []string{"y", "C-m"}, "/dst/a.txt", overwriteYes},
+		{overwriteYes, "/src/older", []string{"n", "C-m"}, "", overwriteNo},
+		{overwriteYes, "/src/older", []string{"u", "C-m"}, "", overwriteNewer},
+		{overwriteYes, "/src/bigger", []string{"s", "C-m"}, "/dst/a.txt", overwriteSizeDiffer},
+		{overwriteYes, "/src/newer", []string{"B", "C-m"}, "/dst/a_2.txt", overwriteKeepBothAll},
+		{overwriteYes, "/src/newer", []string{"r", "C-m", "C-m"}, "/dst/a_2.txt", overwriteRename},
+		{overwriteNo, "/src/newer", []string{"r", "C-m", "C-u", "b.txt", "C-m"}, "/dst/b.txt", overwriteRename},
+		{overwriteYes, "/src/newer", []string{"r", "C-m", "C-u", "C-m"}, "", overwriteRename},
+	}
+	for _, c := range conflicts {
+		g := NewGoful("")
+		g.Workspace().ReloadAll() // for drawing the filer
+		j := newJob(jobCopy, "/dst", []string{c.src}, nil)
+		src, err := mem.Lstat(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dialogKeys(g, c.keys...)
+		policy := c.policy
+		dst, err := g.resolveConflictFS(j, &policy, src, mem, "/dst/a.txt")
+		if err != nil {
+			t.Errorf("%s with %d and %q: %v", c.src, c.policy, c.keys, err)
+			continue
+		}
+		if dst != c.dst || policy != c.after {
+			t.Errorf("%s with %d and %q resolved to %q by %d, want %q by %d", c.src, c.policy, c.keys, dst, policy, c.dst, c.after)
+		}
+	}
+}
+
+func TestRestoreFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the trash is the recycle bin")
+	}
+	dir := t.TempDir()
+	xdg := os.Getenv("XDG_DATA_HOME")
+	os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	defer os.Setenv("XDG_DATA_HOME", xdg)
+
+	files := []string{}
+	for _, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		trashed, err := trash.Move(path)
+		if err != nil {
+			t.Skip(err)
+		}
+		files = append(files, trashed)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("new"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := NewGoful("")
+	g.Workspace().ReloadAll() // for drawing the filer
+	j := newJob(jobRestore, "", files, nil)
+	dialogKeys(g, "n", "C-m", "y", "C-m")
+	n, err := g.restoreFiles(j, files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("restored %d files, want 2", n)
+	}
+	for name, want := range map[string]string{"a": "new", "b": "old", "c": "old"} {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Errorf("%s is %q, want %q (%v)", name, data, want, err)
+		}
+	}
+}
+
+func TestWalkPathsOverlapping(t *testing.T) {
+	mem := vfs.NewMem()
+	if err := mem.WriteFile("/src/a.txt", []byte("remote"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGoful("")
+	filer.SetSyncCallback(nil) // read directories in place
+	defer filer.SetSyncCallback(g.syncCallback)
+	ws := g.Workspace()
+	for len(ws.Dirs) < 2 {
+		ws.CreateDir()
+	}
+	ws.SetFocus(0)
+	remote, local := ws.Dirs[0], ws.Dirs[1]
+	root := filepath.VolumeName(dir) + string(filepath.Separator)
+
+	// the local next directory is in the focused remote directory
+	remote.Mount(mem, root)
+	local.Chdir(dir)
+	srcFS, dstFS, dst, src := g.walkPaths(dir, []string{"/src/a.txt"})
+	if srcFS != mem || !vfs.IsLocal(dstFS) {
+		t.Fatalf("copy from %s to %s%s", srcFS, dstFS, dst)
+	}
+	j := newJob(jobCopy, dst, src, nil)
+	w := g.newWalker(j, overwriteNo, overwriteNo, copyJob{copyOptions{}, nil, srcFS, dstFS})
+	w.src, w.dst = srcFS, dstFS
+	if err := letWalk(w, dst, src...); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "a.txt")); err != nil || string(data) != "remote" {
+		t.Errorf("copied %q, %v to the local disk", data, err)
+	}
+
+	// the remote next directory contains the focused local directory
+	ws.SetFocus(1)
+	dst = filepath.Join(root, "dst")
+	srcFS, dstFS, dst, src = g.walkPaths(dst, []string{"b.txt"})
+	if !vfs.IsLocal(srcFS) || dstFS != mem {
+		t.Fatalf("copy from %s to %s%s", srcFS, dstFS, dst)
+	}
+	j = newJob(jobCopy, dst, src, nil)
+	w = g.newWalker(j, overwriteNo, overwriteNo, copyJob{copyOptions{}, nil, srcFS, dstFS})
+	w.src, w.dst = srcFS, dstFS
+	if err := letWalk(w, dst, src...); err != nil {
+		t.Fatal(err)
+	}
+	r, err := mem.Open(dst)
+	if err != nil {
+		t.Fatalf("not copied to the memory: %v", err)
+	}
+	defer r.Close()
+	if data, err := ioutil.ReadAll(r); err != nil || string(data) != "local" {
+		t.Errorf("copied %q, %v to the memory", data, err)
+	}
+}
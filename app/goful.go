@@ -2,11 +2,16 @@
 package app
 
 import (
+	"sync"
+	"time"
+
 	"github.com/anmitsu/goful/filer"
 	"github.com/anmitsu/goful/info"
 	"github.com/anmitsu/goful/menu"
 	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/preview"
 	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/vfs"
 	"github.com/anmitsu/goful/widget"
 	"github.com/gdamore/tcell/v2"
 )
@@ -20,7 +25,15 @@ type Goful struct {
 	event     chan tcell.Event
 	interrupt chan int
 	callback  chan func()
-	task      chan int
+	jobs      *jobManager
+	journal   *journal
+	copyOpts  copyOptions
+	resumeDir string
+	preview   *preview.Preview     // preview pane or nil
+	duRoot    *duNode              // last scanned disk usage tree
+	watcher   *filer.Watcher       // nil if failed to watch
+	remotes   map[string]*vfs.SFTP // connections by the URL prefix
+	dialogMu  sync.Mutex
 	exit      bool
 }
 
@@ -36,11 +49,17 @@ func NewGoful(path string) *Goful {
 		terminal:  nil,
 		next:      widget.Nil(),
 		event:     make(chan tcell.Event, 1),
-		interrupt: make(chan int, 2),
+		interrupt: make(chan int, 4),
 		callback:  make(chan func()),
-		task:      make(chan int, 1),
+		jobs:      newJobManager(),
+		journal:   newJournal(),
+		remotes:   map[string]*vfs.SFTP{},
 		exit:      false,
 	}
+	redraw := func() { go goful.syncCallback(func() {}) }
+	filer.SetRedraw(redraw)
+	filer.SetSyncCallback(goful.syncCallback)
+	preview.SetRedraw(redraw)
 	return goful
 }
 
@@ -59,6 +78,10 @@ func (g *Goful) ConfigFiler(f func(*Goful) widget.Keymap) {
 	g.MergeKeymap(f(g))
 }
 
+// Workdir returns the focused directory path to resolve relative names in the
+// cmdline.
+func (g *Goful) Workdir() string { return g.Dir().Path }
+
 // Next returns a next widget for drawing and input.
 func (g *Goful) Next() widget.Widget { return g.next }
 
@@ -67,13 +90,10 @@ func (g *Goful) Disconnect() { g.next = widget.Nil() }
 
 // Resize all widgets.
 func (g *Goful) Resize(x, y, width, height int) {
-	offset := 0
-	if !progress.IsFinished() {
-		offset = 2
-	}
+	offset := g.jobs.runningCount() * 2 // for progresses of running jobs
 	g.Filer.Resize(x, y, width, height-2-offset)
 	g.Next().Resize(x, y, width, height-2-offset)
-	progress.Resize(0, height-4, width, 1)
+	progress.Resize(0, height-2-offset, width, offset)
 	message.Resize(0, height-2, width, 1)
 	info.Resize(0, height-1, width, 1)
 }
@@ -81,10 +101,15 @@ func (g *Goful) Resize(x, y, width, height int) {
 // Draw all widgets.
 func (g *Goful) Draw() {
 	g.Filer.Draw()
+	g.drawPreview()
 	g.Next().Draw()
 	progress.Draw()
 	message.Draw()
-	info.Draw(g.File())
+	dir := ""
+	if g.Dir().IsLocal() {
+		dir = g.Dir().Path
+	}
+	info.Draw(dir, g.File())
 }
 
 // Input to a current widget.
@@ -111,6 +136,14 @@ func (g *Goful) Run() {
 	message.Info("Welcome to goful")
 	g.Workspace().ReloadAll()
 
+	watcher, err := filer.NewWatcher(300 * time.Millisecond)
+	if err != nil {
+		message.Error(err)
+	}
+	g.watcher = watcher
+	defer g.watcher.Close()
+	defer g.closeRemotes()
+
 	go func() {
 		for {
 			g.event <- widget.PollEvent()
@@ -118,6 +151,7 @@ func (g *Goful) Run() {
 	}()
 
 	for !g.exit {
+		g.watcher.Sync(g.VisiblePaths())
 		g.Draw()
 		widget.Show()
 		select {
@@ -127,6 +161,8 @@ func (g *Goful) Run() {
 			<-g.interrupt
 		case callback := <-g.callback:
 			callback()
+		case paths := <-g.watcher.Changed():
+			g.Workspace().ReloadPaths(paths)
 		}
 	}
 }
@@ -0,0 +1,413 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/widget"
+)
+
+type jobKind int
+
+const (
+	jobCopy jobKind = iota
+	jobMove
+	jobRemove
+	jobTrash
+	jobRestore
+	jobSync
+	jobArchive
+	jobExtract
+	jobCompare
+)
+
+func (k jobKind) String() string {
+	switch k {
+	case jobCopy:
+		return "copy"
+	case jobMove:
+		return "move"
+	case jobRemove:
+		return "remove"
+	case jobTrash:
+		return "trash"
+	case jobRestore:
+		return "restore"
+	case jobSync:
+		return "sync"
+	case jobArchive:
+		return "archive"
+	case jobExtract:
+		return "extract"
+	case jobCompare:
+		return "compare"
+	}
+	return "unknown"
+}
+
+type jobState int
+
+const (
+	jobQueued jobState = iota
+	jobRunning
+	jobPaused
+	jobDone
+	jobFailed
+	jobCanceled
+)
+
+func (s jobState) String() string {
+	switch s {
+	case jobQueued:
+		return "queued"
+	case jobRunning:
+		return "running"
+	case jobPaused:
+		return "paused"
+	case jobDone:
+		return "done"
+	case jobFailed:
+		return "failed"
+	case jobCanceled:
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// job is a file control such as copy, move and remove queued to the job manager.
+// The context of the job is checked while walking and copying files, so the
+// job can be canceled, paused and resumed.
+type job struct {
+	done       int64 // processed bytes, accessed atomically
+	total      int64 // total bytes, accessed atomically
+	kind       jobKind
+	dst        string
+	src        []string
+	fn         func(j *job) error
+	onFinish   func(err error) // called in the main goroutine after finished if not nil
+	progress   *progress.Progress
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+	mu         sync.Mutex
+	resume     *sync.Cond
+	state      jobState
+	err        error
+}
+
+func newJob(kind jobKind, dst string, src []string, fn func(j *job) error) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		kind:       kind,
+		dst:        dst,
+		src:        src,
+		fn:         fn,
+		ctx:        ctx,
+		cancelFunc: cancel,
+		state:      jobQueued,
+	}
+	j.resume = sync.NewCond(&j.mu)
+	return j
+}
+
+func (j *job) String() string {
+	if j.dst == "" {
+		return fmt.Sprintf("%s %s", j.kind, strings.Join(j.src, " "))
+	}
+	return fmt.Sprintf("%s %s -> %s", j.kind, strings.Join(j.src, " "), j.dst)
+}
+
+// wait blocks while the job is paused and returns an error if the job is canceled.
+func (j *job) wait() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for j.state == jobPaused && j.ctx.Err() == nil {
+		j.resume.Wait()
+	}
+	return j.ctx.Err()
+}
+
+func (j *job) cancel() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cancelFunc()
+	if j.state == jobQueued {
+		j.state = jobCanceled
+	}
+	j.resume.Broadcast()
+}
+
+func (j *job) togglePause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch j.state {
+	case jobRunning:
+		j.state = jobPaused
+	case jobPaused:
+		j.state = jobRunning
+		j.resume.Broadcast()
+	}
+}
+
+func (j *job) getState() jobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+func (j *job) getErr() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+func (j *job) isFinished() bool {
+	switch j.getState() {
+	case jobDone, jobFailed, jobCanceled:
+		return true
+	}
+	return false
+}
+
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.err = err
+	switch {
+	case err == nil:
+		atomic.StoreInt64(&j.done, atomic.LoadInt64(&j.total))
+		j.state = jobDone
+	case errors.Is(err, context.Canceled):
+		j.state = jobCanceled
+	default:
+		j.state = jobFailed
+	}
+	j.cancelFunc()
+}
+
+// jobManager owns the queue of file control jobs.
+type jobManager struct {
+	mu      sync.Mutex
+	jobs    []*job
+	workers int
+	running int
+}
+
+func newJobManager() *jobManager {
+	return &jobManager{
+		jobs:    []*job{},
+		workers: 1,
+		running: 0,
+	}
+}
+
+// SetJobWorkers sets the number of file control jobs running concurrently.
+// Jobs touching the same source or destination trees never run side by side.
+func (g *Goful) SetJobWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	g.jobs.mu.Lock()
+	g.jobs.workers = n
+	g.jobs.mu.Unlock()
+	g.schedule()
+}
+
+func (m *jobManager) runningCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running
+}
+
+// runningJob returns the oldest running or paused job.
+func (m *jobManager) runningJob() *job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		switch j.getState() {
+		case jobRunning, jobPaused:
+			return j
+		}
+	}
+	return nil
+}
+
+// list returns a copy of all jobs in the order of addition.
+func (m *jobManager) list() []*job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*job, len(m.jobs))
+	copy(jobs, m.jobs)
+	return jobs
+}
+
+// remove removes a job from the manager if the job finished.
+func (m *jobManager) remove(j *job) {
+	if !j.isFinished() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.jobs {
+		if e == j {
+			m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// clearFinished removes all finished jobs from the manager.
+func (m *jobManager) clearFinished() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if !j.isFinished() {
+			jobs = append(jobs, j)
+		}
+	}
+	m.jobs = jobs
+}
+
+// asyncFilectrl queues a file control job and runs it when a worker is free.
+// The returned job is finished in the main goroutine, so onFinish can
+// be set after returning.
+func (g *Goful) asyncFilectrl(kind jobKind, dst string, src []string, fn func(j *job) error) *job {
+	j := newJob(kind, dst, src, fn)
+	g.jobs.mu.Lock()
+	g.jobs.jobs = append(g.jobs.jobs, j)
+	g.jobs.mu.Unlock()
+	g.schedule()
+	return j
+}
+
+// retryJob queues again a failed or canceled job.
+func (g *Goful) retryJob(j *job) {
+	switch j.getState() {
+	case jobFailed, jobCanceled:
+		g.jobs.remove(j)
+		g.asyncFilectrl(j.kind, j.dst, j.src, j.fn).onFinish = j.onFinish
+	}
+}
+
+// paths returns source and destination paths of the job.
+func (j *job) paths() []string {
+	if j.dst == "" {
+		return j.src
+	}
+	return append([]string{j.dst}, j.src...)
+}
+
+// overlaps reports whether the job touches the same trees as the other job.
+func (j *job) overlaps(other *job) bool {
+	for _, p1 := range j.paths() {
+		for _, p2 := range other.paths() {
+			if util.IsSubpath(p1, p2) || util.IsSubpath(p2, p1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schedule runs queued jobs within the number of workers.  A job waits for
+// running or earlier queued jobs which touch the same trees.
+// This must be called in the main goroutine for resizing the filer.
+func (g *Goful) schedule() {
+	m := g.jobs
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blockers := []*job{}
+	for _, j := range m.jobs {
+		if m.running >= m.workers {
+			return
+		}
+		switch j.getState() {
+		case jobQueued:
+		case jobRunning, jobPaused:
+			blockers = append(blockers, j)
+			continue
+		default:
+			continue
+		}
+		blocked := false
+		for _, b := range blockers {
+			if j.overlaps(b) {
+				blocked = true
+				break
+			}
+		}
+		blockers = append(blockers, j)
+		if blocked {
+			continue
+		}
+		j.mu.Lock()
+		if j.state == jobQueued {
+			j.state = jobRunning
+			m.running++
+			g.ResizeRelative(0, 0, 0, -2)
+			g.Next().ResizeRelative(0, -2, 0, 0)
+			go g.runJob(j)
+		}
+		j.mu.Unlock()
+	}
+}
+
+func (g *Goful) runJob(j *job) {
+	err := j.fn(j)
+	j.finish(err)
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		message.Infof("Canceled %s", j)
+	default:
+		message.Error(err)
+	}
+	g.syncCallback(func() {
+		g.ResizeRelative(0, 0, 0, 2)
+		g.Next().ResizeRelative(0, 2, 0, 0) // for cmdline and menu
+		widget.Show()
+		g.Workspace().ReloadAll()
+		if j.onFinish != nil {
+			j.onFinish(err)
+		}
+		g.jobs.mu.Lock()
+		g.jobs.running--
+		g.jobs.mu.Unlock()
+		g.schedule()
+	})
+}
+
+// CancelJob cancels the running file control job after confirming.
+func (g *Goful) CancelJob() {
+	j := g.jobs.runningJob()
+	if j == nil {
+		message.Errorf("No running jobs")
+		return
+	}
+	switch g.dialog(fmt.Sprintf("Cancel %s?", j), "y", "n") {
+	case "y", "Y":
+		j.cancel()
+	}
+}
+
+// PauseJob pauses the running file control job or resumes the paused job.
+func (g *Goful) PauseJob() {
+	j := g.jobs.runningJob()
+	if j == nil {
+		message.Errorf("No running jobs")
+		return
+	}
+	j.togglePause()
+	if j.getState() == jobPaused {
+		message.Infof("Paused %s", j)
+	} else {
+		message.Infof("Resumed %s", j)
+	}
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor runs callbacks of jobs in the main goroutine until the condition
+// holds.
+func waitFor(t *testing.T, g *Goful, cond func() bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for !cond() {
+		select {
+		case callback := <-g.callback:
+			callback()
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out")
+		}
+	}
+}
+
+// waitState waits for the job to be in the state.
+func waitState(t *testing.T, g *Goful, j *job, want jobState) {
+	t.Helper()
+	waitFor(t, g, func() bool { return j.getState() == want })
+}
+
+// blockingJob queues a job running until the returned channel is closed.
+func blockingJob(g *Goful, src ...string) (*job, chan struct{}) {
+	release := make(chan struct{})
+	g.asyncFilectrl(jobCopy, "", src, func(j *job) error {
+		select {
+		case <-release:
+			return nil
+		case <-j.ctx.Done():
+			return j.ctx.Err()
+		}
+	})
+	jobs := g.jobs.list()
+	return jobs[len(jobs)-1], release
+}
+
+func TestJobSchedule(t *testing.T) {
+	g := NewGoful("")
+	a, releaseA := blockingJob(g, "/a")
+	b, releaseB := blockingJob(g, "/b")
+	if a.getState() != jobRunning || b.getState() != jobQueued {
+		t.Fatalf("%s is %s and %s is %s with a worker", a, a.getState(), b, b.getState())
+	}
+	close(releaseA)
+	waitState(t, g, a, jobDone)
+	waitState(t, g, b, jobRunning)
+	close(releaseB)
+	waitState(t, g, b, jobDone)
+	waitFor(t, g, func() bool { return g.jobs.runningCount() == 0 })
+}
+
+func TestJobWorkers(t *testing.T) {
+	g := NewGoful("")
+	g.SetJobWorkers(2)
+	a, releaseA := blockingJob(g, "/a")
+	b, releaseB := blockingJob(g, "/b")
+	c, releaseC := blockingJob(g, "/c")
+	if a.getState() != jobRunning || b.getState() != jobRunning || c.getState() != jobQueued {
+		t.Fatalf("states %s, %s, %s with two workers", a.getState(), b.getState(), c.getState())
+	}
+	close(releaseB)
+	waitState(t, g, c, jobRunning)
+	close(releaseA)
+	close(releaseC)
+	waitState(t, g, a, jobDone)
+	waitState(t, g, c, jobDone)
+}
+
+func TestJobBlockedByOverlaps(t *testing.T) {
+	g := NewGoful("")
+	g.SetJobWorkers(3)
+	a, releaseA := blockingJob(g, "/x")
+	b, releaseB := blockingJob(g, "/x/sub")
+	c, releaseC := blockingJob(g, "/xy")
+	if a.getState() != jobRunning || b.getState() != jobQueued || c.getState() != jobRunning {
+		t.Fatalf("states %s, %s, %s with overlapping paths", a.getState(), b.getState(), c.getState())
+	}
+	close(releaseA)
+	waitState(t, g, b, jobRunning)
+	close(releaseB)
+	close(releaseC)
+	waitState(t, g, b, jobDone)
+	waitState(t, g, c, jobDone)
+}
+
+func TestJobOverlaps(t *testing.T) {
+	jobs := []struct {
+		dst1, src1 string
+		dst2, src2 string
+		overlaps   bool
+	}{
+		{"", "/a", "", "/a", true},
+		{"", "/a", "", "/a/b", true},
+		{"", "/a/b", "", "/a", true},
+		{"", "/a", "", "/ab", false},
+		{"/dst", "/a", "", "/dst/c", true},
+		{"/dst", "/a", "/other", "/b", false},
+		{"/dst", "/a", "/a/sub", "/b", true},
+	}
+	for _, tt := range jobs {
+		j1 := newJob(jobCopy, tt.dst1, []string{tt.src1}, nil)
+		j2 := newJob(jobCopy, tt.dst2, []string{tt.src2}, nil)
+		if got := j1.overlaps(j2); got != tt.overlaps {
+			t.Errorf("%s overlaps %s = %v, want %v", j1, j2, got, tt.overlaps)
+		}
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	g := NewGoful("")
+	started, paused := make(chan struct{}), make(chan struct{})
+	g.asyncFilectrl(jobCopy, "", []string{"/a"}, func(j *job) error {
+		close(started)
+		<-paused
+		return j.wait()
+	})
+	a := g.jobs.list()[0]
+	b, _ := blockingJob(g, "/b")
+
+	// cancel the queued job without running
+	b.cancel()
+	if b.getState() != jobCanceled {
+		t.Errorf("canceled queued %s is %s", b, b.getState())
+	}
+
+	<-started
+	a.togglePause()
+	if a.getState() != jobPaused {
+		t.Fatalf("paused %s is %s", a, a.getState())
+	}
+	a.togglePause()
+	if a.getState() != jobRunning {
+		t.Fatalf("resumed %s is %s", a, a.getState())
+	}
+	a.togglePause()
+	close(paused)
+
+	// cancel while paused
+	a.cancel()
+	waitState(t, g, a, jobCanceled)
+	if err := a.getErr(); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled %s with %v", a, err)
+	}
+	waitFor(t, g, func() bool { return g.jobs.runningCount() == 0 })
+}
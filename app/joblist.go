@@ -0,0 +1,171 @@
+package app
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/anmitsu/goful/look"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/widget"
+	"github.com/mattn/go-runewidth"
+)
+
+var jobListKeymap func(*JobList) widget.Keymap
+
+// ConfigJobList sets the job list keymap function.
+func ConfigJobList(config func(*JobList) widget.Keymap) {
+	jobListKeymap = config
+}
+
+// JobList is a list box to display queued, running and finished jobs.
+type JobList struct {
+	*widget.ListBox
+	goful *Goful
+	quit  chan struct{}
+}
+
+// JobList starts the job list mode.
+func (g *Goful) JobList() {
+	if len(g.jobs.list()) < 1 {
+		message.Info("No jobs")
+		return
+	}
+	x, y := g.LeftBottom()
+	w := &JobList{
+		ListBox: widget.NewListBox(x, y, g.Width(), 0, "Jobs"),
+		goful:   g,
+		quit:    make(chan struct{}),
+	}
+	w.update()
+	g.next = w
+
+	go func() { // refresh bytes and states of running jobs
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.syncCallback(func() {})
+			case <-w.quit:
+				return
+			}
+		}
+	}()
+}
+
+// update the list contents by the job manager and resize the height.
+func (w *JobList) update() {
+	jobs := w.goful.jobs.list()
+	list := make([]widget.Drawer, len(jobs))
+	for i, j := range jobs {
+		list[i] = &jobContent{j}
+	}
+	w.SetList(list)
+
+	x, y := w.goful.LeftBottom()
+	height := len(list) + 2
+	if max := w.goful.Height() / 2; height > max {
+		height = max
+	}
+	w.ListBox.Resize(x, y-height+1, w.goful.Width(), height)
+}
+
+func (w *JobList) job() *job {
+	if w.IsEmpty() {
+		return nil
+	}
+	return w.CurrentContent().(*jobContent).job
+}
+
+// Cancel the job on the cursor.
+func (w *JobList) Cancel() {
+	if j := w.job(); j != nil && !j.isFinished() {
+		j.cancel()
+	}
+}
+
+// TogglePause pauses or resumes the job on the cursor.
+func (w *JobList) TogglePause() {
+	if j := w.job(); j != nil {
+		j.togglePause()
+	}
+}
+
+// Retry the failed or canceled job on the cursor.
+func (w *JobList) Retry() {
+	if j := w.job(); j != nil {
+		w.goful.retryJob(j)
+	}
+}
+
+// ClearFinished removes done, failed and canceled jobs from the list.
+func (w *JobList) ClearFinished() {
+	w.goful.jobs.clearFinished()
+	w.update()
+	if w.IsEmpty() {
+		w.Exit()
+	}
+}
+
+// Resize the job list by the filer size.
+func (w *JobList) Resize(x, y, width, height int) {
+	w.update()
+}
+
+// Draw the job list after updating contents.
+func (w *JobList) Draw() {
+	w.update()
+	w.ListBox.Draw()
+}
+
+// Input to the list box.
+func (w *JobList) Input(key string) {
+	if callback, ok := jobListKeymap(w)[key]; ok {
+		callback()
+	}
+}
+
+// Exit the job list mode.
+func (w *JobList) Exit() {
+	close(w.quit)
+	w.goful.Disconnect()
+}
+
+// Next implements widget.Widget.
+func (w *JobList) Next() widget.Widget { return widget.Nil() }
+
+// Disconnect implements widget.Widget.
+func (w *JobList) Disconnect() {}
+
+type jobContent struct {
+	job *job
+}
+
+func (c *jobContent) Name() string { return c.job.String() }
+
+func (c *jobContent) Draw(x, y, width int, focus bool) {
+	j := c.job
+	state := j.getState()
+	done := util.FormatSize(atomic.LoadInt64(&j.done))
+	total := util.FormatSize(atomic.LoadInt64(&j.total))
+	s := fmt.Sprintf("%-8s %7s/%-7s %s", state, done, total, j)
+	if err := j.getErr(); err != nil && state == jobFailed {
+		s += ": " + err.Error()
+	}
+	s = runewidth.Truncate(s, width, "~")
+	s = runewidth.FillRight(s, width)
+
+	style := look.Default()
+	switch state {
+	case jobRunning:
+		style = look.MessageInfo()
+	case jobFailed:
+		style = look.MessageError()
+	}
+	if focus {
+		style = style.Reverse(true)
+	}
+	widget.SetCells(x, y, s, style)
+}
@@ -0,0 +1,311 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/trash"
+	"github.com/anmitsu/goful/util"
+)
+
+type journalOp string
+
+const (
+	journalRename journalOp = "rename"
+	journalMove   journalOp = "move"
+	journalMkdir  journalOp = "mkdir"
+	journalTouch  journalOp = "touch"
+	journalTrash  journalOp = "trash"
+	journalRemove journalOp = "remove"
+)
+
+// journalPair is a path changed from Src to Dst.  For trashing, Dst is the
+// trashed file path.
+type journalPair struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// journalEntry is a recorded file operation with data to invert it.
+type journalEntry struct {
+	Op           journalOp     `json:"op"`
+	Time         time.Time     `json:"time"`
+	Pairs        []journalPair `json:"pairs,omitempty"`
+	Paths        []string      `json:"paths,omitempty"` // created or removed paths
+	Mode         os.FileMode   `json:"mode,omitempty"`
+	Irreversible bool          `json:"irreversible,omitempty"`
+}
+
+func (e *journalEntry) String() string {
+	if len(e.Pairs) > 0 {
+		if len(e.Pairs) == 1 {
+			return fmt.Sprintf("%s %s -> %s", e.Op, e.Pairs[0].Src, e.Pairs[0].Dst)
+		}
+		return fmt.Sprintf("%s %d files", e.Op, len(e.Pairs))
+	}
+	return fmt.Sprintf("%s %s", e.Op, strings.Join(e.Paths, " "))
+}
+
+// journal is the operation history for undo and redo.  Entries before pos are
+// done and entries from pos are undone and redoable.  The journal is saved to
+// the path in every change, so undo works after restarting.
+type journal struct {
+	mu      sync.Mutex
+	path    string
+	Entries []*journalEntry `json:"entries"`
+	Pos     int             `json:"pos"`
+}
+
+const journalMax = 100
+
+func newJournal() *journal {
+	return &journal{Entries: []*journalEntry{}}
+}
+
+// SetJournal sets the path to save the undo journal and loads the journal
+// from the path if exists.  The journal is not saved if the path is "".
+func (g *Goful) SetJournal(path string) error {
+	g.journal.mu.Lock()
+	defer g.journal.mu.Unlock()
+	g.journal.path = util.ExpandPath(path)
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(g.journal.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, g.journal); err != nil {
+		return err
+	}
+	if g.journal.Pos < 0 || g.journal.Pos > len(g.journal.Entries) {
+		g.journal.Pos = len(g.journal.Entries)
+	}
+	return nil
+}
+
+func (j *journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(j.path, data, 0644)
+}
+
+// record adds the entry and discards redoable entries.
+func (j *journal) record(e *journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e.Time = time.Now()
+	j.Entries = append(j.Entries[:j.Pos], e)
+	if len(j.Entries) > journalMax {
+		j.Entries = j.Entries[len(j.Entries)-journalMax:]
+	}
+	j.Pos = len(j.Entries)
+	if err := j.save(); err != nil {
+		message.Error(err)
+	}
+}
+
+// Undo inverts the last file operation recorded in the journal.
+func (g *Goful) Undo() {
+	if g.journal.undo() {
+		g.Workspace().ReloadAll()
+	}
+}
+
+// undo inverts the last entry and reports whether the journal changed.
+func (j *journal) undo() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Pos < 1 {
+		message.Info("Nothing to undo")
+		return false
+	}
+	e := j.Entries[j.Pos-1]
+	if e.Irreversible {
+		j.Pos--
+		message.Errorf("Cannot undo %s (irreversible)", e)
+	} else if err := e.undo(); err != nil {
+		message.Error(err)
+		return false
+	} else {
+		j.Pos--
+		message.Infof("Undid %s", e)
+	}
+	if err := j.save(); err != nil {
+		message.Error(err)
+	}
+	return true
+}
+
+// Redo does the file operation undone last again.
+func (g *Goful) Redo() {
+	if g.journal.redo() {
+		g.Workspace().ReloadAll()
+	}
+}
+
+// redo does the entry undone last again and reports whether the journal
+// changed.
+func (j *journal) redo() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Pos >= len(j.Entries) {
+		message.Info("Nothing to redo")
+		return false
+	}
+	e := j.Entries[j.Pos]
+	if e.Irreversible {
+		j.Pos++
+		message.Errorf("Cannot redo %s (irreversible)", e)
+	} else if err := e.redo(); err != nil {
+		message.Error(err)
+		return false
+	} else {
+		j.Pos++
+		message.Infof("Redid %s", e)
+	}
+	if err := j.save(); err != nil {
+		message.Error(err)
+	}
+	return true
+}
+
+func (e *journalEntry) undo() error {
+	switch e.Op {
+	case journalRename, journalMove:
+		pairs := make([]journalPair, 0, len(e.Pairs))
+		for i := len(e.Pairs) - 1; i >= 0; i-- {
+			pairs = append(pairs, journalPair{e.Pairs[i].Dst, e.Pairs[i].Src})
+		}
+		return renamePairs(pairs)
+	case journalMkdir:
+		for i := len(e.Paths) - 1; i >= 0; i-- {
+			if err := os.Remove(e.Paths[i]); err != nil {
+				return err
+			}
+		}
+	case journalTouch:
+		for _, path := range e.Paths {
+			if stat, err := os.Stat(path); err != nil {
+				return err
+			} else if stat.Size() != 0 {
+				return fmt.Errorf("cannot remove %s modified after touch", path)
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	case journalTrash:
+		for _, p := range e.Pairs {
+			t, err := trash.Lookup(p.Dst)
+			if err != nil {
+				return err
+			}
+			if err := t.Restore(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown journal operation %s", e.Op)
+	}
+	return nil
+}
+
+func (e *journalEntry) redo() error {
+	switch e.Op {
+	case journalRename, journalMove:
+		return renamePairs(e.Pairs)
+	case journalMkdir:
+		for _, path := range e.Paths {
+			if err := os.Mkdir(path, e.Mode); err != nil {
+				return err
+			}
+		}
+	case journalTouch:
+		for _, path := range e.Paths {
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL, e.Mode)
+			if err != nil {
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+		}
+	case journalTrash:
+		for i, p := range e.Pairs {
+			trashed, err := trash.Move(p.Src)
+			if err != nil {
+				return err
+			}
+			e.Pairs[i].Dst = trashed
+		}
+	default:
+		return fmt.Errorf("unknown journal operation %s", e.Op)
+	}
+	return nil
+}
+
+// renamePairs renames sources of the pairs to destinations in order.  If
+// failed, the renamed pairs are renamed back in reverse order, so the entry
+// stays undoable or redoable as a whole.
+func renamePairs(pairs []journalPair) error {
+	for i, p := range pairs {
+		err := renameNoReplace(p.Src, p.Dst)
+		if err == nil {
+			continue
+		}
+		for k := i - 1; k >= 0; k-- {
+			if rerr := renameNoReplace(pairs[k].Dst, pairs[k].Src); rerr != nil {
+				return fmt.Errorf("%v (rolling back: %v)", err, rerr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// renameNoReplace renames the path without overwriting an existing file.
+func renameNoReplace(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: os.ErrExist}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// createdDirs returns directories created by os.MkdirAll(path) from the top.
+func createdDirs(path string) []string {
+	dirs := []string{}
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			break
+		}
+		dirs = append([]string{path}, dirs...)
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return dirs
+}
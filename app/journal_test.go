@@ -0,0 +1,166 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anmitsu/goful/vfs"
+)
+
+func TestJournalUndoRedo(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a")
+	dst := filepath.Join(dir, "b")
+	if err := ioutil.WriteFile(src, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	mkdir := filepath.Join(dir, "x", "y")
+	dirs := createdDirs(mkdir)
+	if len(dirs) != 2 || dirs[0] != filepath.Join(dir, "x") {
+		t.Fatalf("createdDirs(%q)=%q", mkdir, dirs)
+	}
+	if err := os.MkdirAll(mkdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "journal.json")
+	j := newJournal()
+	j.path = path
+	j.record(&journalEntry{Op: journalRename, Pairs: []journalPair{{src, dst}}})
+	j.record(&journalEntry{Op: journalMkdir, Paths: dirs, Mode: 0755})
+
+	// reload the saved journal as after restarting
+	g := &Goful{journal: newJournal()}
+	if err := g.SetJournal(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.journal.Entries) != 2 || g.journal.Pos != 2 {
+		t.Fatalf("loaded %d entries at %d, want 2 at 2", len(g.journal.Entries), g.journal.Pos)
+	}
+	for i := len(g.journal.Entries) - 1; i >= 0; i-- {
+		if err := g.journal.Entries[i].undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "x")); !os.IsNotExist(err) {
+		t.Errorf("undo mkdir remains %s", dirs[0])
+	}
+	if _, err := os.Lstat(src); err != nil {
+		t.Errorf("undo rename: %v", err)
+	}
+
+	for _, e := range g.journal.Entries {
+		if err := e.redo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Lstat(dst); err != nil {
+		t.Errorf("redo rename: %v", err)
+	}
+	if stat, err := os.Stat(mkdir); err != nil || !stat.IsDir() {
+		t.Errorf("redo mkdir: %v", err)
+	}
+
+	// undo must not overwrite an existing file
+	if err := ioutil.WriteFile(src, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.journal.Entries[0].undo(); !os.IsExist(err) {
+		t.Errorf("undo rename to existing %s: %v, want exist error", src, err)
+	}
+}
+
+func TestMoveJournal(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"d/x", "b", "out/b"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out")
+	src := []string{filepath.Join(dir, "d"), filepath.Join(dir, "b")}
+	g := &Goful{}
+	j := newJob(jobMove, out, src, nil)
+	entry := &journalEntry{Op: journalMove}
+	w := g.newWalker(j, overwriteNoAll, overwriteNo, moveJob{copyOptions{}, vfs.Local, vfs.Local, entry})
+	w.moved = entry
+	if err := letWalk(w, out, src...); err != nil {
+		t.Fatal(err)
+	}
+	// the skipped file is not recorded
+	if len(entry.Pairs) != 1 || entry.Pairs[0] != (journalPair{src[0], filepath.Join(out, "d")}) || entry.Irreversible {
+		t.Fatalf("recorded %+v", entry)
+	}
+	if err := entry.undo(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(src[0], "x")); err != nil || string(data) != "d/x" {
+		t.Errorf("undo move: %q, %v", data, err)
+	}
+
+	// moves by copying across file systems are irreversible
+	mem := vfs.NewMem()
+	if err := mem.Mkdir("/out", 0755); err != nil {
+		t.Fatal(err)
+	}
+	j = newJob(jobMove, "/out", src[:1], nil)
+	entry = &journalEntry{Op: journalMove}
+	w = g.newWalker(j, overwriteNo, overwriteNo, moveJob{copyOptions{}, vfs.Local, mem, entry})
+	w.dst, w.moved = mem, entry
+	if err := letWalk(w, "/out", src[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Pairs) != 1 || !entry.Irreversible {
+		t.Errorf("recorded %+v across file systems", entry)
+	}
+}
+
+func TestJournalRollback(t *testing.T) {
+	dir := t.TempDir()
+	pairs := []journalPair{}
+	for _, name := range []string{"a", "b"} {
+		src, dst := filepath.Join(dir, name), filepath.Join(dir, name+".new")
+		if err := ioutil.WriteFile(dst, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, journalPair{src, dst})
+	}
+	e := &journalEntry{Op: journalRename, Pairs: pairs}
+
+	// undoing "b.new" is rolled back as "a" exists
+	if err := ioutil.WriteFile(pairs[0].Src, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.undo(); !os.IsExist(err) {
+		t.Fatalf("undo to existing %s: %v, want exist error", pairs[0].Src, err)
+	}
+	for _, p := range pairs {
+		if _, err := os.Lstat(p.Dst); err != nil {
+			t.Errorf("not rolled back to %s: %v", p.Dst, err)
+		}
+	}
+	if _, err := os.Lstat(pairs[1].Src); !os.IsNotExist(err) {
+		t.Errorf("%s remains after rolling back", pairs[1].Src)
+	}
+
+	if err := os.Remove(pairs[0].Src); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.undo(); err != nil {
+		t.Fatalf("undo again: %v", err)
+	}
+	for _, p := range pairs {
+		if _, err := os.Lstat(p.Src); err != nil {
+			t.Errorf("undo: %v", err)
+		}
+	}
+}
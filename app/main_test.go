@@ -0,0 +1,25 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"github.com/anmitsu/goful/cmdline"
+	"github.com/anmitsu/goful/widget"
+	"github.com/gdamore/tcell/v2"
+)
+
+// TestMain draws to a simulation screen, so dialogs can be answered by
+// sending key events.
+func TestMain(m *testing.M) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		panic(err)
+	}
+	widget.SetScreen(screen)
+	cmdline.Config(func(c *cmdline.Cmdline) widget.Keymap {
+		return widget.Keymap{"C-m": c.Run, "C-u": c.KillLineAll}
+	})
+	// not finalize the screen which messages of finished jobs may still show
+	os.Exit(m.Run())
+}
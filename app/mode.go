@@ -9,18 +9,53 @@ import (
 	"strings"
 
 	"github.com/anmitsu/goful/cmdline"
+	"github.com/anmitsu/goful/filer"
 	"github.com/anmitsu/goful/look"
 	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/trash"
 	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
 	"github.com/anmitsu/goful/widget"
 )
 
 // match shell separators, macros, options and spaces.
-var re = regexp.MustCompile(`([;|>&])|(%~?(?:[&mMfFxX]|[dD]2?))|([[:space:]]-[[:word:]-=]+)|[[:space:]]`)
+var re = regexp.MustCompile(`([;|>&])|(%~?(?:[&mMfFxXl]|[dD]2?))|([[:space:]]-[[:word:]-=]+)|[[:space:]]`)
+
+// localOnly reports whether the directories are on the local disk, or shows
+// the error of the operation supported only on the local disk.
+func (g *Goful) localOnly(dirs ...*filer.Directory) bool {
+	for _, d := range dirs {
+		if !d.IsLocal() {
+			message.Errorf("Not supported on %s", d.FS())
+			return false
+		}
+	}
+	return true
+}
+
+// writable reports whether files listed in the directories can be changed, or
+// shows the error.  Archives are read-only, and trashed files are changed only
+// by restoring and emptying the trash, not to orphan their trash info files.
+func (g *Goful) writable(dirs ...*filer.Directory) bool {
+	for _, d := range dirs {
+		if arc, _ := d.ArchiveDir(); arc != "" {
+			message.Errorf("Read-only archive %s", arc)
+			return false
+		}
+		if d.IsTrash() {
+			message.Errorf("Not supported in the trash listing, restore or empty the trash")
+			return false
+		}
+	}
+	return true
+}
 
 // Shell starts the shell mode.
 // The head of variadic arguments is used for cursor positioning.
 func (g *Goful) Shell(cmd string, offset ...int) {
+	if !g.localOnly(g.Dir()) {
+		return
+	}
 	commands, err := util.SearchCommands()
 	if err != nil {
 		message.Error(err)
@@ -36,6 +71,9 @@ func (g *Goful) Shell(cmd string, offset ...int) {
 // ShellSuspend starts the shell mode and suspends screen after running.
 // The head of variadic arguments is used for cursor positioning.
 func (g *Goful) ShellSuspend(cmd string, offset ...int) {
+	if !g.localOnly(g.Dir()) {
+		return
+	}
 	commands, err := util.SearchCommands()
 	if err != nil {
 		message.Error(err)
@@ -83,7 +121,7 @@ func (m *shellMode) drawCommand(x, y int, cmd string) {
 		s = cmd[start:match[1]]
 		if match[2] != -1 { // as shell separator ;|>&
 			x = widget.SetCells(x, y, s, look.Cmdline())
-		} else if match[4] != -1 { // as macro %& %m %M %f %F %x %X %d2 %D %d2 %D2
+		} else if match[4] != -1 { // as macro %& %m %M %f %F %x %X %l %d2 %D %d2 %D2
 			x = widget.SetCells(x, y, s, look.CmdlineMacro())
 		} else if match[6] != -1 { // as option -a --bcd-efg
 			x = widget.SetCells(x, y, s, look.CmdlineOption())
@@ -111,21 +149,41 @@ func (m *shellMode) Run(c *cmdline.Cmdline) {
 	c.Exit()
 }
 
+// dialog waits for one of options in the cmdline.  Dialogs of concurrent jobs
+// are displayed one by one, and the interrupt channel has room for the pairs of
+// a job dialog and a dialog in the main goroutine.
 func (g *Goful) dialog(message string, options ...string) string {
+	dialog := &dialogMode{message, options, ""}
+	g.interact(cmdline.New(dialog, g))
+	return dialog.result
+}
+
+// inputDialog is a dialog to input a text with the initial text, and returns
+// the input or "" if canceled.
+func (g *Goful) inputDialog(prompt, text string) string {
+	input := &inputMode{prompt, ""}
+	c := cmdline.New(input, g)
+	c.SetText(text)
+	g.interact(c)
+	return input.result
+}
+
+// interact handles events for the cmdline until exiting in the interrupted
+// main loop.
+func (g *Goful) interact(c *cmdline.Cmdline) {
+	g.dialogMu.Lock()
+	defer g.dialogMu.Unlock()
 	g.interrupt <- 1
 	defer func() { g.interrupt <- 1 }()
 
 	tmp := g.Next()
-	dialog := &dialogMode{message, options, ""}
-	g.next = cmdline.New(dialog, g)
-
+	g.next = c
 	for !widget.IsNil(g.Next()) {
 		g.Draw()
 		widget.Show()
 		g.eventHandler(<-g.event)
 	}
 	g.next = tmp
-	return dialog.result
 }
 
 type dialogMode struct {
@@ -150,6 +208,19 @@ func (m *dialogMode) Run(c *cmdline.Cmdline) {
 	c.SetText("")
 }
 
+type inputMode struct {
+	prompt string
+	result string
+}
+
+func (m *inputMode) String() string          { return "input" }
+func (m *inputMode) Prompt() string          { return m.prompt }
+func (m *inputMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
+func (m *inputMode) Run(c *cmdline.Cmdline) {
+	m.result = c.String()
+	c.Exit()
+}
+
 // Quit starts the quit mode.
 func (g *Goful) Quit() {
 	g.next = cmdline.New(&quitMode{g}, g)
@@ -174,11 +245,15 @@ func (m quitMode) Run(c *cmdline.Cmdline) {
 	}
 }
 
-// Copy starts the copy mode.
+// Copy starts the copy mode.  In an archive, entries are copied out from the
+// archive.
 func (g *Goful) Copy() {
 	c := cmdline.New(&copyMode{g, ""}, g)
 	if g.Dir().IsMark() {
 		c.SetText(g.Workspace().NextDir().Path)
+	} else if g.Dir().IsArchive() {
+		c = cmdline.New(&copyMode{g, g.File().Path()}, g)
+		c.SetText(g.Workspace().NextDir().Path)
 	} else {
 		c.SetText(g.File().Name())
 	}
@@ -219,6 +294,9 @@ func (m *copyMode) Run(c *cmdline.Cmdline) {
 
 // Move starts the move mode.
 func (g *Goful) Move() {
+	if !g.writable(g.Dir()) {
+		return
+	}
 	c := cmdline.New(&moveMode{g, ""}, g)
 	if g.Dir().IsMark() {
 		c.SetText(g.Workspace().NextDir().Path)
@@ -260,8 +338,65 @@ func (m *moveMode) Run(c *cmdline.Cmdline) {
 	}
 }
 
+// Archive starts the archive mode to create an archive of mark files.  The
+// format is by the extension such as ".zip", ".tar", ".tar.gz" and ".tar.xz".
+func (g *Goful) Archive(ext string) {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
+	name := g.Dir().Base()
+	if !g.Dir().IsMark() && g.File().Name() != ".." {
+		name = util.RemoveExt(g.File().Name())
+	}
+	c := cmdline.New(&archiveMode{g}, g)
+	c.SetText(name + ext)
+	c.MoveCursor(-len(ext))
+	g.next = c
+}
+
+type archiveMode struct {
+	*Goful
+}
+
+func (m *archiveMode) String() string { return "archive" }
+func (m *archiveMode) Prompt() string {
+	return fmt.Sprintf("Archive %d files to ", len(m.Dir().MarkfilePaths()))
+}
+func (m *archiveMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
+func (m *archiveMode) Run(c *cmdline.Cmdline) {
+	m.archive(c.String(), m.Dir().MarkfilePaths()...)
+	c.Exit()
+}
+
+// Extract starts the extract mode to extract mark archive files.
+func (g *Goful) Extract() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
+	c := cmdline.New(&extractMode{g}, g)
+	c.SetText(g.Dir().Path)
+	g.next = c
+}
+
+type extractMode struct {
+	*Goful
+}
+
+func (m *extractMode) String() string { return "extract" }
+func (m *extractMode) Prompt() string {
+	return fmt.Sprintf("Extract %d archives to ", len(m.Dir().MarkfilePaths()))
+}
+func (m *extractMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
+func (m *extractMode) Run(c *cmdline.Cmdline) {
+	m.extractArchives(c.String(), m.Dir().MarkfilePaths()...)
+	c.Exit()
+}
+
 // Rename starts the rename mode.
 func (g *Goful) Rename() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
 	src := g.File().Name()
 	c := cmdline.New(&renameMode{g, src}, g)
 	c.SetText(src)
@@ -289,6 +424,9 @@ func (m *renameMode) Run(c *cmdline.Cmdline) {
 
 // BulkRename starts the bulk rename mode.
 func (g *Goful) BulkRename() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
 	g.next = cmdline.New(&bulkRenameMode{g, ""}, g)
 }
 
@@ -314,9 +452,25 @@ func (m *bulkRenameMode) Run(c *cmdline.Cmdline) {
 	m.bulkRename(pattern, repl, m.Dir().Markfiles()...)
 }
 
-// Remove starts the remove mode.
+// Remove starts the remove mode moving files to the trash, or deleting files
+// permanently if the trash is unsupported.
 func (g *Goful) Remove() {
-	c := cmdline.New(&removeMode{g, ""}, g)
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
+	c := cmdline.New(&removeMode{g, "", !trash.Supported()}, g)
+	if !g.Dir().IsMark() {
+		c.SetText(g.File().Name())
+	}
+	g.next = c
+}
+
+// RemovePermanently starts the remove mode deleting files permanently.
+func (g *Goful) RemovePermanently() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
+	c := cmdline.New(&removeMode{g, "", true}, g)
 	if !g.Dir().IsMark() {
 		c.SetText(g.File().Name())
 	}
@@ -325,17 +479,19 @@ func (g *Goful) Remove() {
 
 type removeMode struct {
 	*Goful
-	src string
+	src       string
+	permanent bool
 }
 
 func (m *removeMode) String() string { return "remove" }
 func (m *removeMode) Prompt() string {
+	action := removeAction(m.permanent)
 	if m.Dir().IsMark() {
-		return fmt.Sprintf("Remove %d mark files? [y/n] ", m.Dir().MarkCount())
+		return fmt.Sprintf("%s %d mark files? [y/n] ", action, m.Dir().MarkCount())
 	} else if m.src != "" {
-		return fmt.Sprintf("Remove? %s [y/n] ", m.src)
+		return fmt.Sprintf("%s? %s [y/n] ", action, m.src)
 	} else {
-		return "Remove: "
+		return action + ": "
 	}
 }
 func (m *removeMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
@@ -343,10 +499,14 @@ func (m *removeMode) Run(c *cmdline.Cmdline) {
 	if marked := m.Dir().IsMark(); marked || m.src != "" {
 		switch c.String() {
 		case "y", "Y":
+			files := []string{m.src}
 			if marked {
-				m.remove(m.Dir().MarkfilePaths()...)
+				files = m.Dir().MarkfilePaths()
+			}
+			if m.permanent {
+				m.remove(files...)
 			} else {
-				m.remove(m.src)
+				m.trash(files...)
 			}
 			c.Exit()
 		case "n", "N":
@@ -360,8 +520,76 @@ func (m *removeMode) Run(c *cmdline.Cmdline) {
 	}
 }
 
+// removeAction returns the action name of removing files to confirm.
+func removeAction(permanent bool) string {
+	if !permanent {
+		return "Trash"
+	} else if !trash.Supported() {
+		return "Trash unsupported, remove permanently"
+	}
+	return "Remove permanently"
+}
+
+// EmptyTrash starts the mode to delete marked trashed files permanently, or all
+// trashed files unless marked in the trash listing.
+func (g *Goful) EmptyTrash() {
+	g.next = cmdline.New(&emptyTrashMode{g}, g)
+}
+
+type emptyTrashMode struct {
+	*Goful
+}
+
+func (m *emptyTrashMode) selective() bool {
+	return m.Dir().IsTrash() && m.Dir().IsMark()
+}
+
+func (m *emptyTrashMode) String() string { return "emptytrash" }
+func (m *emptyTrashMode) Prompt() string {
+	if m.selective() {
+		return fmt.Sprintf("Empty %d mark files from the trash? [y/n] ", m.Dir().MarkCount())
+	}
+	return "Empty the trash? [y/n] "
+}
+func (m *emptyTrashMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
+func (m *emptyTrashMode) Run(c *cmdline.Cmdline) {
+	switch c.String() {
+	case "y", "Y":
+		c.Exit()
+		var entries []*trash.Entry
+		if m.selective() {
+			for _, file := range m.Dir().MarkfilePaths() {
+				e, err := trash.Lookup(file)
+				if err != nil {
+					message.Error(err)
+					return
+				}
+				entries = append(entries, e)
+			}
+		} else {
+			var err error
+			if entries, err = trash.List(); err != nil {
+				message.Error(err)
+				return
+			}
+		}
+		if len(entries) < 1 {
+			message.Info("The trash is empty")
+			return
+		}
+		m.emptyTrash(entries...)
+	case "n", "N":
+		c.Exit()
+	default:
+		c.SetText("")
+	}
+}
+
 // Mkdir starts the make directory mode.
 func (g *Goful) Mkdir() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
 	g.next = cmdline.New(&mkdirMode{g, ""}, g)
 }
 
@@ -384,15 +612,12 @@ func (m *mkdirMode) Run(c *cmdline.Cmdline) {
 		if mode != "" {
 			if mode, err := strconv.ParseUint(mode, 8, 32); err != nil {
 				message.Error(err)
-			} else if err := os.MkdirAll(m.path, os.FileMode(mode)); err != nil {
-				message.Error(err)
+			} else {
+				m.mkdir(m.path, os.FileMode(mode))
 			}
 		} else {
-			if err := os.MkdirAll(m.path, 0755); err != nil {
-				message.Error(err)
-			}
+			m.mkdir(m.path, 0755)
 		}
-		message.Info("Made directory " + m.path)
 		c.Exit()
 		m.Workspace().ReloadAll()
 	} else {
@@ -403,6 +628,9 @@ func (m *mkdirMode) Run(c *cmdline.Cmdline) {
 
 // Touch starts the touch file mode.
 func (g *Goful) Touch() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
 	g.next = cmdline.New(&touchFileMode{g, ""}, g)
 }
 
@@ -441,6 +669,9 @@ func (m *touchFileMode) Run(c *cmdline.Cmdline) {
 
 // Chmod starts the change mode mode.
 func (g *Goful) Chmod() {
+	if !g.localOnly(g.Dir()) || !g.writable(g.Dir()) {
+		return
+	}
 	c := cmdline.New(&chmodMode{g, nil}, g)
 	if !g.Dir().IsMark() {
 		c.SetText(g.File().Name())
@@ -526,7 +757,11 @@ func (m *chdirMode) Prompt() string          { return "Chdir to " }
 func (m *chdirMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
 func (m *chdirMode) Run(c *cmdline.Cmdline) {
 	if path := c.String(); path != "" {
-		m.Dir().Chdir(path)
+		if vfs.IsSFTPURL(path) {
+			m.ChdirRemote(path)
+		} else {
+			m.Dir().Chdir(path)
+		}
 		c.Exit()
 	}
 }
@@ -568,3 +803,22 @@ func (m *globdirMode) Run(c *cmdline.Cmdline) {
 		c.Exit()
 	}
 }
+
+// Grep starts the grep mode.
+func (g *Goful) Grep() {
+	g.next = cmdline.New(&grepMode{g}, g)
+}
+
+type grepMode struct {
+	*Goful
+}
+
+func (m *grepMode) String() string          { return "grep" }
+func (m *grepMode) Prompt() string          { return "Grep regexp: " }
+func (m *grepMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
+func (m *grepMode) Run(c *cmdline.Cmdline) {
+	if pattern := c.String(); pattern != "" {
+		m.Dir().Grep(pattern)
+		c.Exit()
+	}
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anmitsu/goful/filer"
+	"github.com/anmitsu/goful/trash"
+	"github.com/anmitsu/goful/widget"
+)
+
+func TestWritableInTrash(t *testing.T) {
+	xdg := os.Getenv("XDG_DATA_HOME")
+	os.Setenv("XDG_DATA_HOME", filepath.Join(t.TempDir(), "data"))
+	defer os.Setenv("XDG_DATA_HOME", xdg)
+
+	g := NewGoful("")
+	filer.SetSyncCallback(nil) // read directories in place
+	defer filer.SetSyncCallback(g.syncCallback)
+	g.Dir().Chdir(t.TempDir())
+	g.Dir().Trash()
+	if !g.Dir().IsTrash() {
+		t.Fatal("not listing the trash")
+	}
+	for name, op := range map[string]func(){
+		"remove":             g.Remove,
+		"remove permanently": g.RemovePermanently,
+		"rename":             g.Rename,
+		"bulk rename":        g.BulkRename,
+		"move":               g.Move,
+		"mkdir":              g.Mkdir,
+		"touch":              g.Touch,
+		"chmod":              g.Chmod,
+	} {
+		op()
+		if !widget.IsNil(g.Next()) {
+			t.Errorf("%s started in the trash listing", name)
+			g.Disconnect()
+		}
+	}
+}
+
+func TestWritableInArchive(t *testing.T) {
+	dir := t.TempDir()
+	file, err := os.Create(filepath.Join(dir, "a.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(file)
+	if _, err := w.Create("inner/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	g := NewGoful("")
+	filer.SetSyncCallback(nil) // read archives in place
+	defer filer.SetSyncCallback(g.syncCallback)
+	ws := g.Workspace()
+	for len(ws.Dirs) < 2 {
+		ws.CreateDir()
+	}
+	ws.SetFocus(0)
+	ws.Dirs[1].Chdir(dir)
+	g.Dir().Chdir(dir)
+	g.Dir().SetCursorByName("a.zip")
+	g.Dir().EnterArchive()
+	if !g.Dir().IsArchive() {
+		t.Fatal("not listing the archive")
+	}
+	for name, op := range map[string]func(){
+		"remove": g.Remove,
+		"rename": g.Rename,
+		"move":   g.Move,
+		"mkdir":  g.Mkdir,
+		"touch":  g.Touch,
+		"chmod":  g.Chmod,
+	} {
+		op()
+		if !widget.IsNil(g.Next()) {
+			t.Errorf("%s started in the archive listing", name)
+			g.Disconnect()
+		}
+	}
+
+	// copying into the archive is refused, and out of it is extracted
+	if !g.inArchive(filepath.Join(dir, "a.zip", "inner")) {
+		t.Error("copy into the focused archive")
+	}
+	if g.inArchive(dir) {
+		t.Error("extracting to the directory of the focused archive refused")
+	}
+	ws.SetFocus(1)
+	if !g.inArchive(dir) {
+		t.Error("copy into the next archive listing")
+	}
+}
+
+func TestRemoveAction(t *testing.T) {
+	want := "Trash"
+	if !trash.Supported() {
+		want = "Trash unsupported, remove permanently"
+	}
+	if action := removeAction(!trash.Supported()); action != want {
+		t.Errorf("remove action %q, want %q", action, want)
+	}
+	if action := removeAction(true); trash.Supported() && action != "Remove permanently" {
+		t.Errorf("remove permanently action %q", action)
+	}
+}
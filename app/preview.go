@@ -0,0 +1,55 @@
+package app
+
+import (
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/preview"
+)
+
+// TogglePreview shows or hides the preview pane taking the place of the next
+// directory.  The pane follows the file on the cursor.
+func (g *Goful) TogglePreview() {
+	if g.preview != nil {
+		g.preview.Close()
+		g.preview = nil
+		return
+	}
+	if len(g.Workspace().Dirs) < 2 {
+		message.Errorf("Preview pane needs two or more directories")
+		return
+	}
+	g.preview = preview.New(0, 0, 0, 0, nil)
+}
+
+// drawPreview draws the preview pane over the next directory.
+func (g *Goful) drawPreview() {
+	if g.preview == nil {
+		return
+	}
+	ws := g.Workspace()
+	next := ws.NextDir()
+	if next == ws.Dir() {
+		return
+	}
+	x, y := next.LeftTop()
+	if cx, cy := ws.Dir().LeftTop(); x == cx && y == cy { // fullscreen layout
+		return
+	}
+	if !ws.Dir().IsLocal() { // files on other file systems are not previewed
+		return
+	}
+	g.preview.Resize(x, y, next.Width(), next.Height())
+	g.preview.Open(g.File().Path())
+	g.preview.Draw()
+}
+
+// View starts the preview mode displaying the file on the cursor over the
+// filer to scroll and search.
+func (g *Goful) View() {
+	if !g.localOnly(g.Dir()) {
+		return
+	}
+	x, y := g.LeftTop()
+	p := preview.New(x, y, g.Width(), g.Height(), g)
+	p.Open(g.File().Path())
+	g.next = p
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/vfs"
+)
+
+// ChdirRemote connects to the host of the SFTP URL in the background and
+// mounts the path, or the home directory if omitted, in the focused directory.
+// Connections are shared by directories on the same host.
+func (g *Goful) ChdirRemote(url string) {
+	addr, path, err := vfs.ParseSFTPURL(url)
+	if err != nil {
+		message.Error(err)
+		return
+	}
+	d := g.Dir()
+	key := addr.String()
+	if s, ok := g.remotes[key]; ok {
+		if s.Alive() {
+			g.mountRemote(s, path)
+			return
+		}
+		s.Close()
+		delete(g.remotes, key)
+	}
+	message.Infof("Connecting to %s", key)
+	go func() {
+		s, err := vfs.DialSFTP(addr)
+		g.syncCallback(func() {
+			if err != nil {
+				message.Error(err)
+				return
+			}
+			if old, ok := g.remotes[key]; ok { // connected twice
+				s.Close()
+				s = old
+			} else {
+				g.remotes[key] = s
+			}
+			if d == g.Dir() {
+				g.mountRemote(s, path)
+			}
+		})
+	}()
+}
+
+func (g *Goful) mountRemote(s *vfs.SFTP, path string) {
+	if path == "" {
+		home, err := s.Getwd()
+		if err != nil {
+			message.Error(err)
+			return
+		}
+		path = home
+	}
+	g.Dir().Mount(s, path)
+}
+
+// closeRemotes closes all connections.
+func (g *Goful) closeRemotes() {
+	for key, s := range g.remotes {
+		s.Close()
+		delete(g.remotes, key)
+	}
+}
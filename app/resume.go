@@ -0,0 +1,196 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anmitsu/goful/cmdline"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
+)
+
+// resumeManifest records a running copy job to resume after quitting or
+// crashing.  The file being copied is recorded to continue the partial file.
+type resumeManifest struct {
+	path    string
+	resumed bool      // the job has run before
+	saved   time.Time // time of the last save
+	Dst     string    `json:"dst"`
+	Src     []string  `json:"src"`
+	Partial string    `json:"partial,omitempty"` // the destination file being copied
+}
+
+func newResumeManifest(dir, dst string, src []string) *resumeManifest {
+	name := fmt.Sprintf("%d.json", time.Now().UnixNano())
+	return &resumeManifest{path: filepath.Join(dir, name), Dst: dst, Src: src}
+}
+
+// SetResumeDir sets the directory to write resume manifests of copy jobs, and
+// starts the mode to resume unfinished copies if manifests remain.  Copies are
+// not resumable if the directory is "".
+func (g *Goful) SetResumeDir(dir string) {
+	g.resumeDir = util.ExpandPath(dir)
+	if dir == "" {
+		return
+	}
+	manifests, err := loadResumeManifests(g.resumeDir)
+	if err != nil {
+		message.Error(err)
+		return
+	}
+	if len(manifests) > 0 {
+		g.next = cmdline.New(&resumeMode{g, manifests}, g)
+	}
+}
+
+func loadResumeManifests(dir string) ([]*resumeManifest, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	manifests := []*resumeManifest{}
+	for _, fi := range files {
+		if !strings.HasSuffix(fi.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		m := &resumeManifest{path: path}
+		if err := json.Unmarshal(data, m); err != nil {
+			message.Errorf("Broken resume manifest %s: %s", path, err)
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+// start saves the manifest and reports whether the job has run before.
+func (m *resumeManifest) start() error {
+	if _, err := os.Stat(m.path); err == nil {
+		m.resumed = true
+	}
+	return m.save()
+}
+
+// save writes the manifest to a temporary file and renames it so as not to
+// leave a broken manifest.
+func (m *resumeManifest) save() error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return err
+	}
+	m.saved = time.Now()
+	return nil
+}
+
+func (m *resumeManifest) remove() {
+	if err := os.Remove(m.path); err != nil && !os.IsNotExist(err) {
+		message.Error(err)
+	}
+}
+
+const (
+	resumeLargeSize    = 1 << 20     // files always saved to continue copying
+	resumeSaveInterval = time.Second // least interval of saving smaller files
+)
+
+// record sets the destination file to be copied, and saves the manifest if the
+// source file is large or a while passed since the last save.  The saved
+// partial file may be stale, which is safe as resuming verifies files by the
+// size and the modification time.
+func (m *resumeManifest) record(srcstat os.FileInfo, dst string) {
+	m.Partial = dst
+	if srcstat.Size() < resumeLargeSize && time.Since(m.saved) < resumeSaveInterval {
+		return
+	}
+	if err := m.save(); err != nil {
+		message.Error(err)
+	}
+}
+
+// copied reports whether the source was copied to the destination before
+// resuming, verified by the size and the modification time.
+func (m *resumeManifest) copied(srcstat os.FileInfo, src, dst string) bool {
+	if !m.resumed || m.isPartial(dst) {
+		return false
+	}
+	dststat, err := os.Lstat(dst)
+	if err != nil {
+		return false
+	}
+	if srcstat.Mode()&os.ModeSymlink != 0 {
+		srclink, err1 := os.Readlink(src)
+		dstlink, err2 := os.Readlink(dst)
+		return err1 == nil && err2 == nil && srclink == dstlink
+	}
+	return dststat.Mode().IsRegular() && dststat.Size() == srcstat.Size() &&
+		dststat.ModTime().Unix() == srcstat.ModTime().Unix()
+}
+
+// isPartial reports whether the destination was being copied before resuming.
+func (m *resumeManifest) isPartial(dst string) bool {
+	return m.resumed && dst == m.Partial
+}
+
+// partialOffset returns the length of the destination file partially copied
+// before resuming, or 0 if the destination is not partial.
+func (m *resumeManifest) partialOffset(srcstat os.FileInfo, dst string) int64 {
+	if !m.isPartial(dst) || !srcstat.Mode().IsRegular() {
+		return 0
+	}
+	dststat, err := os.Lstat(dst)
+	if err != nil || !dststat.Mode().IsRegular() || dststat.Size() >= srcstat.Size() {
+		return 0
+	}
+	return dststat.Size()
+}
+
+type resumeMode struct {
+	*Goful
+	manifests []*resumeManifest
+}
+
+func (m *resumeMode) String() string { return "resume" }
+func (m *resumeMode) Prompt() string {
+	return fmt.Sprintf("Resume %d unfinished copies (n discards)? [y/n] ", len(m.manifests))
+}
+func (m *resumeMode) Draw(c *cmdline.Cmdline) { c.DrawLine() }
+func (m *resumeMode) Run(c *cmdline.Cmdline) {
+	switch c.String() {
+	case "y", "Y":
+		c.Exit()
+		for _, manifest := range m.manifests {
+			m.copyWithManifest(vfs.Local, vfs.Local, manifest.Dst, manifest.Src, manifest)
+		}
+	case "n", "N":
+		c.Exit()
+		for _, manifest := range m.manifests {
+			manifest.remove()
+		}
+	default:
+		c.SetText("")
+	}
+}
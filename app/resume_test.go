@@ -0,0 +1,113 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResumeManifest(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.Local)
+	write := func(path, content string, mtime time.Time) os.FileInfo {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		stat, _ := os.Stat(path)
+		return stat
+	}
+	done := write(filepath.Join(src, "done"), "done", mtime)
+	write(filepath.Join(dst, "done"), "done", mtime)
+	partial := write(filepath.Join(src, "partial"), "partial", mtime)
+	write(filepath.Join(dst, "partial"), "par", time.Now())
+
+	m := newResumeManifest(filepath.Join(dir, "resume"), dst, []string{src})
+	if err := m.start(); err != nil {
+		t.Fatal(err)
+	}
+	if m.resumed {
+		t.Error("new manifest is resumed")
+	}
+	m.saved = time.Time{} // saved long ago
+	m.record(partial, filepath.Join(dst, "partial"))
+
+	manifests, err := loadResumeManifests(filepath.Join(dir, "resume"))
+	if err != nil || len(manifests) != 1 {
+		t.Fatalf("loadResumeManifests() returns %d manifests, %v", len(manifests), err)
+	}
+	m = manifests[0]
+	if err := m.start(); err != nil {
+		t.Fatal(err)
+	}
+	if !m.resumed {
+		t.Error("loaded manifest is not resumed")
+	}
+	if !m.copied(done, filepath.Join(src, "done"), filepath.Join(dst, "done")) {
+		t.Error("copied file is not skipped")
+	}
+	if m.copied(partial, filepath.Join(src, "partial"), filepath.Join(dst, "partial")) {
+		t.Error("partial file is skipped")
+	}
+	if offset := m.partialOffset(partial, filepath.Join(dst, "partial")); offset != 3 {
+		t.Errorf("partialOffset()=%d, want 3", offset)
+	}
+	m.remove()
+	if manifests, _ := loadResumeManifests(filepath.Join(dir, "resume")); len(manifests) != 0 {
+		t.Errorf("%d manifests remain after remove", len(manifests))
+	}
+}
+
+func TestResumeRecord(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small")
+	large := filepath.Join(dir, "large")
+	if err := ioutil.WriteFile(small, []byte("small"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(large, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(large, resumeLargeSize); err != nil {
+		t.Fatal(err)
+	}
+	smallstat, _ := os.Stat(small)
+	largestat, _ := os.Stat(large)
+
+	m := newResumeManifest(filepath.Join(dir, "resume"), dir, []string{dir})
+	if err := m.start(); err != nil {
+		t.Fatal(err)
+	}
+	saved := func() string {
+		manifests, err := loadResumeManifests(filepath.Join(dir, "resume"))
+		if err != nil || len(manifests) != 1 {
+			t.Fatalf("loadResumeManifests() returns %d manifests, %v", len(manifests), err)
+		}
+		return manifests[0].Partial
+	}
+	m.record(smallstat, "small")
+	if partial := saved(); partial != "" {
+		t.Errorf("saved %q recording the small file just after the last save", partial)
+	}
+	m.record(largestat, "large")
+	if partial := saved(); partial != "large" {
+		t.Errorf("saved %q recording the large file, want large", partial)
+	}
+	m.saved = m.saved.Add(-resumeSaveInterval)
+	m.record(smallstat, "small")
+	if partial := saved(); partial != "small" {
+		t.Errorf("saved %q recording the small file after the interval, want small", partial)
+	}
+}
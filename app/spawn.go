@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/anmitsu/goful/message"
@@ -14,6 +15,9 @@ import (
 
 // Spawn a process by the shell or the terminal.
 func (g *Goful) Spawn(cmd string) {
+	if !g.localOnly(g.Dir()) {
+		return
+	}
 	cmd, background := g.expandMacro(cmd)
 	var args []string
 	if background {
@@ -22,6 +26,7 @@ func (g *Goful) Spawn(cmd string) {
 		args = g.terminal(cmd)
 	}
 	execCmd := exec.Command(args[0], args[1:]...)
+	execCmd.Dir = g.Dir().Path
 	message.Info(strings.Join(execCmd.Args, " "))
 	if err := spawn(execCmd); err != nil {
 		message.Error(err)
@@ -54,9 +59,13 @@ func spawn(cmd *exec.Cmd) error {
 
 // SpawnSuspend spawns a process and suspends screen.
 func (g *Goful) SpawnSuspend(cmd string) {
+	if !g.localOnly(g.Dir()) {
+		return
+	}
 	cmd, _ = g.expandMacro(cmd)
 	args := g.shell(cmd)
 	execCmd := exec.Command(args[0], args[1:]...)
+	execCmd.Dir = g.Dir().Path
 	execCmd.Stdin = os.Stdin
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
@@ -68,6 +77,7 @@ func (g *Goful) SpawnSuspend(cmd string) {
 	_ = execCmd.Run()
 
 	shell := exec.Command(args[0])
+	shell.Dir = g.Dir().Path
 	shell.Stdin = os.Stdin
 	shell.Stdout = os.Stdout
 	shell.Stderr = os.Stderr
@@ -87,6 +97,7 @@ const (
 	macroDir                = 'd'  // %d %~d are expanded a directory name on the cursor
 	macroDirPath            = 'D'  // %D %~D are expanded a directory path on the cursor
 	macroNextDir            = '2'  // %d2 %D2 %~d2 %~D2 are expanded the neighbor directory name or path
+	macroLine               = 'l'  // %l %~l are expanded a line number of the grep match on the cursor or 1
 	macroRunBackground      = '&'  // %& is a flag runned in background
 )
 
@@ -177,6 +188,12 @@ func (g *Goful) expandMacro(cmd string) (result string, background bool) {
 				if !nonQuote {
 					src = util.Quote(src)
 				}
+			case macroLine:
+				line := g.File().Line()
+				if line < 1 {
+					line = 1
+				}
+				src = strconv.Itoa(line)
 			case macroRunBackground:
 				background = true
 			default:
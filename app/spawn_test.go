@@ -36,6 +36,8 @@ func TestExpandMacro(t *testing.T) {
 		{`%~D`, home},
 		{`%~d2`, filepath.Base(home)},
 		{`%~D2`, home},
+		{`%l`, "1"},
+		{`%~l`, "1"},
 		{`%%%f`, `%%".."`},
 		{`%%%~f`, `%%..`},
 		{`%~~f`, `%~~f`},
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/progress"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
+)
+
+type syncStatus int
+
+const (
+	syncNew     syncStatus = iota // only in the left
+	syncNewer                     // the left is newer
+	syncOlder                     // the left is older
+	syncDiffer                    // the same time but the size or type differs
+	syncMissing                   // only in the right
+)
+
+func (s syncStatus) String() string {
+	switch s {
+	case syncNew:
+		return "new"
+	case syncNewer:
+		return "newer"
+	case syncOlder:
+		return "older"
+	case syncDiffer:
+		return "differ"
+	case syncMissing:
+		return "missing"
+	}
+	return "unknown"
+}
+
+// syncItem is a path relative to compared directories that differs.
+type syncItem struct {
+	rel    string
+	status syncStatus
+	left   os.FileInfo // nil if only in the right
+	right  os.FileInfo // nil if only in the left
+}
+
+// compareTrees compares the left and right directories recursively and returns
+// differing items sorted by the relative path.  Directories only in one side
+// are an item not including children.
+func compareTrees(left, right string) ([]*syncItem, error) {
+	items := []*syncItem{}
+	if err := compareDir(left, right, "", &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func compareDir(left, right, rel string, items *[]*syncItem) error {
+	lfiles, err := readDirMap(vfs.Local, filepath.Join(left, rel))
+	if err != nil {
+		return err
+	}
+	rfiles, err := readDirMap(vfs.Local, filepath.Join(right, rel))
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(lfiles)+len(rfiles))
+	for name := range lfiles {
+		names = append(names, name)
+	}
+	for name := range rfiles {
+		if _, ok := lfiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		l, r := lfiles[name], rfiles[name]
+		path := filepath.Join(rel, name)
+		switch {
+		case r == nil:
+			*items = append(*items, &syncItem{path, syncNew, l, nil})
+		case l == nil:
+			*items = append(*items, &syncItem{path, syncMissing, nil, r})
+		case l.IsDir() && r.IsDir():
+			if err := compareDir(left, right, path, items); err != nil {
+				return err
+			}
+		default:
+			if status, ok := compareFile(filepath.Join(left, path), filepath.Join(right, path), l, r); !ok {
+				*items = append(*items, &syncItem{path, status, l, r})
+			}
+		}
+	}
+	return nil
+}
+
+func readDirMap(fsys vfs.FS, dir string) (map[string]os.FileInfo, error) {
+	files := map[string]os.FileInfo{}
+	if err := fsys.ReadDir(dir, func(fi os.FileInfo) bool {
+		files[fi.Name()] = fi
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// compareFile returns the status and true if the files are the same.  Files
+// are the same if the types, sizes and modification times in seconds match, or
+// symlinks link to the same path.
+func compareFile(lpath, rpath string, l, r os.FileInfo) (syncStatus, bool) {
+	if l.Mode().Type() == r.Mode().Type() {
+		if l.Mode()&os.ModeSymlink != 0 {
+			llink, err1 := os.Readlink(lpath)
+			rlink, err2 := os.Readlink(rpath)
+			if err1 == nil && err2 == nil && llink == rlink {
+				return syncDiffer, true
+			}
+		} else if l.Size() == r.Size() && l.ModTime().Unix() == r.ModTime().Unix() {
+			return syncDiffer, true
+		}
+	}
+	switch lt, rt := l.ModTime().Unix(), r.ModTime().Unix(); {
+	case lt > rt:
+		return syncNewer, false
+	case lt < rt:
+		return syncOlder, false
+	}
+	return syncDiffer, false
+}
+
+type syncPolicy int
+
+const (
+	syncUpdate syncPolicy = iota // copy new and newer files to the right
+	syncMirror                   // make the right the same as the left with deletions
+	syncTwoWay                   // copy newer files to both sides, the newest wins
+)
+
+func (p syncPolicy) String() string {
+	switch p {
+	case syncUpdate:
+		return "update"
+	case syncMirror:
+		return "mirror"
+	case syncTwoWay:
+		return "two-way"
+	}
+	return "unknown"
+}
+
+// syncAction copies the path from to the path to, or removes the path remove.
+type syncAction struct {
+	from   string
+	to     string
+	remove string
+}
+
+// syncActions returns actions to apply the policy to the items.
+func syncActions(policy syncPolicy, left, right string, items []*syncItem) []syncAction {
+	actions := []syncAction{}
+	toRight := func(item *syncItem) {
+		actions = append(actions, syncAction{from: filepath.Join(left, item.rel), to: filepath.Join(right, item.rel)})
+	}
+	toLeft := func(item *syncItem) {
+		actions = append(actions, syncAction{from: filepath.Join(right, item.rel), to: filepath.Join(left, item.rel)})
+	}
+	for _, item := range items {
+		switch policy {
+		case syncUpdate:
+			switch item.status {
+			case syncNew, syncNewer:
+				toRight(item)
+			}
+		case syncMirror:
+			if item.status == syncMissing {
+				actions = append(actions, syncAction{remove: filepath.Join(right, item.rel)})
+			} else {
+				toRight(item)
+			}
+		case syncTwoWay:
+			switch item.status {
+			case syncNew, syncNewer:
+				toRight(item)
+			case syncMissing, syncOlder:
+				toLeft(item)
+			}
+		}
+	}
+	return actions
+}
+
+// sync applies the policy to the compared items as a job.  Replaced files
+// are overwritten without confirming.
+func (g *Goful) sync(policy syncPolicy, left, right string, items []*syncItem) {
+	actions := syncActions(policy, left, right, items)
+	if len(actions) < 1 {
+		message.Infof("Nothing to %s", policy)
+		return
+	}
+	opts := g.copyOpts.forJob()
+	g.asyncFilectrl(jobSync, right, []string{left}, func(j *job) error {
+		paths := make([]string, len(actions))
+		removed := []string{}
+		for i, a := range actions {
+			if a.remove != "" {
+				paths[i] = a.remove
+				removed = append(removed, a.remove)
+			} else {
+				paths[i] = a.from
+			}
+		}
+		if len(removed) > 0 {
+			g.journal.record(&journalEntry{Op: journalRemove, Paths: removed, Irreversible: true})
+		}
+		size, count := util.CalcSizeCount(paths...)
+		atomic.StoreInt64(&j.total, size)
+		j.progress = progress.Start(float64(size), count)
+		defer j.progress.Finish()
+
+		walker := g.newWalker(j, overwriteYesAll, overwriteYesAll, copyJob{opts, nil, vfs.Local, vfs.Local})
+		for _, a := range actions {
+			if a.remove != "" {
+				if err := removeAll(j, vfs.Local, a.remove); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := replaceType(j, a.from, a.to); err != nil {
+				return err
+			}
+			if err := walker.copyExact(a.from, a.to); err != nil {
+				return err
+			}
+		}
+		message.Infof("Synchronized (%s) %s and %s", policy, left, right)
+		return nil
+	})
+}
+
+// copyExact copies the path from to the exact path to, while walk copies into
+// the existing directory to and follows the symlink to a directory.
+func (w *walker) copyExact(from, to string) error {
+	if err := w.job.wait(); err != nil {
+		return err
+	}
+	stat, err := w.src.Lstat(from)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return w.dir2dir(from, to)
+	}
+	_, err = w.file2file(from, to)
+	return err
+}
+
+// replaceType removes the path to if the type differs from the path from, or
+// both are symlinks which are not overwritten in place.
+func replaceType(j *job, from, to string) error {
+	fromstat, err := os.Lstat(from)
+	if err != nil {
+		return err
+	}
+	tostat, err := os.Lstat(to)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fromstat.Mode().Type() != tostat.Mode().Type() || fromstat.Mode()&os.ModeSymlink != 0 {
+		return removeAll(j, vfs.Local, to)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/anmitsu/goful/filer"
+)
+
+func TestCompareTrees(t *testing.T) {
+	left, right := t.TempDir(), t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	write := func(dir, name, data string, mtime time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(left, "same", "a", now)
+	write(right, "same", "a", now)
+	write(left, "new", "a", now)
+	write(right, "missing", "a", now)
+	write(left, "sub/newer", "a", now)
+	write(right, "sub/newer", "a", now.Add(-time.Hour))
+	write(left, "sub/older", "a", now.Add(-time.Hour))
+	write(right, "sub/older", "a", now)
+	write(left, "differ", "a", now)
+	write(right, "differ", "ab", now)
+	write(left, "newdir/file", "a", now)
+
+	items, err := compareTrees(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		rel    string
+		status syncStatus
+	}{
+		{"differ", syncDiffer},
+		{"missing", syncMissing},
+		{"new", syncNew},
+		{"newdir", syncNew},
+		{filepath.Join("sub", "newer"), syncNewer},
+		{filepath.Join("sub", "older"), syncOlder},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("compareTrees returns %d items, want %d", len(items), len(want))
+	}
+	for i, w := range want {
+		if items[i].rel != w.rel || items[i].status != w.status {
+			t.Errorf("items[%d]=%s %s, want %s %s", i, items[i].rel, items[i].status, w.rel, w.status)
+		}
+	}
+
+	actions := syncActions(syncMirror, left, right, items)
+	if len(actions) != len(items) || actions[1].remove != filepath.Join(right, "missing") {
+		t.Errorf("mirror actions %v", actions)
+	}
+	actions = syncActions(syncTwoWay, left, right, items)
+	if len(actions) != 5 || actions[0].to != filepath.Join(left, "missing") {
+		t.Errorf("two-way actions %v", actions)
+	}
+}
+
+func TestSyncSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need the privilege on windows")
+	}
+	left, right := t.TempDir(), t.TempDir()
+	if err := os.Symlink("x", filepath.Join(left, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(right, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", filepath.Join(right, "link")); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGoful("")
+	filer.SetSyncCallback(nil) // reload directories in place
+	defer filer.SetSyncCallback(g.syncCallback)
+	items := []*syncItem{{rel: "link", status: syncNewer}}
+	g.sync(syncUpdate, left, right, items)
+	waitFor(t, g, func() bool {
+		link, _ := os.Readlink(filepath.Join(right, "link"))
+		return link == "x"
+	})
+	if _, err := os.Lstat(filepath.Join(right, "dir", "link")); !os.IsNotExist(err) {
+		t.Errorf("copied the symlink into the linked directory")
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anmitsu/goful/look"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/widget"
+	"github.com/mattn/go-runewidth"
+)
+
+var syncListKeymap func(*SyncList) widget.Keymap
+
+// ConfigSyncList sets the sync list keymap function.
+func ConfigSyncList(config func(*SyncList) widget.Keymap) {
+	syncListKeymap = config
+}
+
+// SyncList is a list box to preview differences between the focused directory
+// (left) and the next directory (right) before synchronizing.
+type SyncList struct {
+	*widget.ListBox
+	goful *Goful
+	left  string
+	right string
+	items []*syncItem
+}
+
+// Sync compares the focused directory with the next directory recursively
+// and starts the sync list mode to preview differences.
+func (g *Goful) Sync() {
+	if !g.localOnly(g.Dir(), g.Workspace().NextDir()) {
+		return
+	}
+	left, right := g.Dir().Path, g.Workspace().NextDir().Path
+	if left == right {
+		message.Errorf("Cannot sync %s with itself", left)
+		return
+	}
+	message.Infof("Comparing %s and %s", left, right)
+	go func() {
+		items, err := compareTrees(left, right)
+		g.syncCallback(func() {
+			if err != nil {
+				message.Error(err)
+				return
+			}
+			if len(items) < 1 {
+				message.Infof("No differences between %s and %s", left, right)
+				return
+			}
+			x, y := g.LeftBottom()
+			title := fmt.Sprintf("Sync %s -> %s", left, right)
+			w := &SyncList{
+				ListBox: widget.NewListBox(x, y, g.Width(), 0, title),
+				goful:   g,
+				left:    left,
+				right:   right,
+				items:   items,
+			}
+			w.update()
+			g.next = w
+		})
+	}()
+}
+
+// update the list contents by the compared items and resize the height.
+func (w *SyncList) update() {
+	list := make([]widget.Drawer, len(w.items))
+	for i, item := range w.items {
+		list[i] = &syncContent{item}
+	}
+	w.SetList(list)
+
+	x, y := w.goful.LeftBottom()
+	height := len(list) + 2
+	if max := w.goful.Height() / 2; height > max {
+		height = max
+	}
+	w.ListBox.Resize(x, y-height+1, w.goful.Width(), height)
+}
+
+func (w *SyncList) apply(policy syncPolicy) {
+	w.Exit()
+	w.goful.sync(policy, w.left, w.right, w.items)
+}
+
+// Update copies new and newer files from the left to the right.
+func (w *SyncList) Update() { w.apply(syncUpdate) }
+
+// Mirror makes the right the same as the left, deleting files missing in the
+// left.
+func (w *SyncList) Mirror() { w.apply(syncMirror) }
+
+// TwoWay copies newer files to both sides and the newest file wins.
+func (w *SyncList) TwoWay() { w.apply(syncTwoWay) }
+
+// Resize the sync list by the filer size.
+func (w *SyncList) Resize(x, y, width, height int) {
+	w.update()
+}
+
+// Input to the list box.
+func (w *SyncList) Input(key string) {
+	if callback, ok := syncListKeymap(w)[key]; ok {
+		callback()
+	}
+}
+
+// Exit the sync list mode.
+func (w *SyncList) Exit() {
+	w.goful.Disconnect()
+}
+
+// Next implements widget.Widget.
+func (w *SyncList) Next() widget.Widget { return widget.Nil() }
+
+// Disconnect implements widget.Widget.
+func (w *SyncList) Disconnect() {}
+
+type syncContent struct {
+	item *syncItem
+}
+
+func (c *syncContent) Name() string { return c.item.rel }
+
+func (c *syncContent) Draw(x, y, width int, focus bool) {
+	item := c.item
+	name := fmt.Sprintf("%-7s %s", item.status, item.rel)
+	if item.left != nil && item.left.IsDir() || item.right != nil && item.right.IsDir() {
+		name += string(filepath.Separator)
+	}
+	stats := fmt.Sprintf(" %s | %s", syncStat(item.left), syncStat(item.right))
+	namewidth := width - runewidth.StringWidth(stats)
+	if namewidth < 0 {
+		namewidth = 0
+	}
+	name = runewidth.Truncate(name, namewidth, "~")
+	s := runewidth.FillRight(name, namewidth) + stats
+	s = runewidth.Truncate(s, width, "~")
+
+	style := look.Default()
+	switch item.status {
+	case syncNew, syncNewer:
+		style = look.MessageInfo()
+	case syncMissing, syncOlder:
+		style = look.MessageError()
+	}
+	if focus {
+		style = style.Reverse(true)
+	}
+	widget.SetCells(x, y, s, style)
+}
+
+// syncStat returns the size and the modification time of one side, or blanks
+// if the file is missing.
+func syncStat(fi os.FileInfo) string {
+	if fi == nil {
+		return fmt.Sprintf("%7s %14s", "-", "-")
+	}
+	return fmt.Sprintf("%7s %14s", util.FormatSize(fi.Size()), fi.ModTime().Format(conflictTimeFormat))
+}
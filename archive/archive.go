@@ -0,0 +1,278 @@
+// Package archive reads zip and tar archives compressed by gzip, bzip2 or xz
+// to browse and extract entries without external tools.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+type format int
+
+const (
+	formatZip format = iota
+	formatTar
+	formatTarGz
+	formatTarBz2
+	formatTarXz
+)
+
+// formats by extensions in order of matching, longer extensions first.
+var formats = []struct {
+	ext    string
+	format format
+}{
+	{".tar.gz", formatTarGz},
+	{".tar.bz2", formatTarBz2},
+	{".tar.xz", formatTarXz},
+	{".tgz", formatTarGz},
+	{".tbz", formatTarBz2},
+	{".tbz2", formatTarBz2},
+	{".txz", formatTarXz},
+	{".tar", formatTar},
+	{".zip", formatZip},
+}
+
+func formatOf(name string) (format, bool) {
+	name = strings.ToLower(name)
+	for _, f := range formats {
+		if strings.HasSuffix(name, f.ext) {
+			return f.format, true
+		}
+	}
+	return 0, false
+}
+
+// IsArchive reports whether the file name has an extension of a supported
+// archive format.
+func IsArchive(name string) bool {
+	_, ok := formatOf(name)
+	return ok
+}
+
+// Entry is a file stored in an archive.
+type Entry struct {
+	Name     string // slash separated path in the archive without the trailing slash
+	Mode     os.FileMode
+	Size     int64
+	ModTime  time.Time
+	Linkname string // target of a symlink or a hard link to a former entry
+	Uname    string
+	Gname    string
+}
+
+// Info returns the file info of the entry.  Sys returns the entry.
+func (e *Entry) Info() os.FileInfo { return entryInfo{e} }
+
+// Owner returns the user and the group name of the entry if stored.
+func (e *Entry) Owner() (string, string) { return e.Uname, e.Gname }
+
+type entryInfo struct {
+	e *Entry
+}
+
+func (i entryInfo) Name() string       { return path.Base(i.e.Name) }
+func (i entryInfo) Size() int64        { return i.e.Size }
+func (i entryInfo) Mode() os.FileMode  { return i.e.Mode }
+func (i entryInfo) ModTime() time.Time { return i.e.ModTime }
+func (i entryInfo) IsDir() bool        { return i.e.Mode.IsDir() }
+func (i entryInfo) Sys() interface{}   { return i.e }
+
+// cleanName returns the entry name as a relative slash path or "" as the
+// archive root, or an error if the name contains ".." to point outside of the
+// archive root.
+func cleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("unsafe entry name %q", name)
+		}
+	}
+	return path.Clean("/" + name)[1:], nil
+}
+
+// Walk calls fn for each entry in the archive order with a reader of contents.
+// The reader is valid only in the call and empty for non-regular files.
+func Walk(file string, fn func(e *Entry, r io.Reader) error) error {
+	f, ok := formatOf(file)
+	if !ok {
+		return fmt.Errorf("unsupported archive %s", file)
+	}
+	if f == formatZip {
+		return walkZip(file, fn)
+	}
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	var r io.Reader = fd
+	switch f {
+	case formatTarGz:
+		gz, err := gzip.NewReader(fd)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case formatTarBz2:
+		r = bzip2.NewReader(fd)
+	case formatTarXz:
+		if r, err = xz.NewReader(fd); err != nil {
+			return err
+		}
+	}
+	return walkTar(r, fn)
+}
+
+func walkTar(r io.Reader, fn func(e *Entry, r io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name, err := cleanName(hdr.Name)
+		if err != nil {
+			return err
+		} else if name == "" {
+			continue
+		}
+		e := &Entry{
+			Name:     name,
+			Mode:     hdr.FileInfo().Mode(),
+			Size:     hdr.Size,
+			ModTime:  hdr.ModTime,
+			Linkname: hdr.Linkname,
+			Uname:    hdr.Uname,
+			Gname:    hdr.Gname,
+		}
+		if err := fn(e, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(file string, fn func(e *Entry, r io.Reader) error) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		name, err := cleanName(f.Name)
+		if err != nil {
+			return err
+		} else if name == "" {
+			continue
+		}
+		e := &Entry{
+			Name:    name,
+			Mode:    f.Mode(),
+			Size:    int64(f.UncompressedSize64),
+			ModTime: f.Modified,
+		}
+		if err := walkZipFile(f, e, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkZipFile(f *zip.File, e *Entry, fn func(e *Entry, r io.Reader) error) error {
+	if e.Mode.IsDir() {
+		return fn(e, strings.NewReader(""))
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if e.Mode&os.ModeSymlink != 0 {
+		link, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		e.Linkname = string(link)
+		e.Size = 0
+		return fn(e, strings.NewReader(""))
+	}
+	return fn(e, rc)
+}
+
+// List returns all entries in the archive sorted by the name, including
+// parent directories not stored in the archive.
+func List(file string) ([]*Entry, error) {
+	return ListContext(context.Background(), file)
+}
+
+// ListContext is List stopping with the context error if the context is done
+// while decompressing the archive.
+func ListContext(ctx context.Context, file string) ([]*Entry, error) {
+	entries := map[string]*Entry{}
+	var modTime time.Time
+	if stat, err := os.Stat(file); err == nil {
+		modTime = stat.ModTime()
+	}
+	err := Walk(file, func(e *Entry, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries[e.Name] = e
+		for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := entries[dir]; ok {
+				break
+			}
+			entries[dir] = &Entry{Name: dir, Mode: os.ModeDir | 0755, ModTime: modTime}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Entry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// ReadDir returns entries directly in the directory of the archive.  The
+// directory is a slash path or "" as the archive root.
+func ReadDir(entries []*Entry, dir string) []*Entry {
+	list := []*Entry{}
+	for _, e := range entries {
+		parent := path.Dir(e.Name)
+		if parent == "." {
+			parent = ""
+		}
+		if parent == dir {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// Lookup returns the entry of the name in the entries.
+func Lookup(entries []*Entry, name string) (*Entry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Name >= name })
+	if i < len(entries) && entries[i].Name == name {
+		return entries[i], true
+	}
+	return nil, false
+}
@@ -0,0 +1,248 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+type testFile struct {
+	name, body, link string
+	mode             int64
+	typ              byte
+}
+
+func writeTar(t *testing.T, w io.Writer, files []testFile) {
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: f.mode, Size: int64(len(f.body)),
+			Typeflag: f.typ, Linkname: f.link, ModTime: time.Unix(1600000000, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var testFiles = []testFile{
+	{name: "./", mode: 0755, typ: tar.TypeDir},
+	{name: "a/b/c.txt", body: "hello", mode: 0644, typ: tar.TypeReg},
+	{name: "a/link", link: "b/c.txt", mode: 0777, typ: tar.TypeSymlink},
+	{name: "top.txt", body: "top", mode: 0600, typ: tar.TypeReg},
+	{name: "a/hard", link: "top.txt", mode: 0600, typ: tar.TypeLink},
+}
+
+func TestListExtract(t *testing.T) {
+	dir := t.TempDir()
+	tgz := filepath.Join(dir, "test.tar.gz")
+	fd, err := os.Create(tgz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(fd)
+	writeTar(t, gz, testFiles)
+	gz.Close()
+	fd.Close()
+
+	txz := filepath.Join(dir, "test.txz")
+	if fd, err = os.Create(txz); err != nil {
+		t.Fatal(err)
+	}
+	xw, err := xz.NewWriter(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTar(t, xw, testFiles)
+	xw.Close()
+	fd.Close()
+
+	for _, file := range []string{tgz, txz} {
+		entries, err := List(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a and a/b are implied by a/b/c.txt
+		names := []string{"a", "a/b", "a/b/c.txt", "a/hard", "a/link", "top.txt"}
+		if len(entries) != len(names) {
+			t.Fatalf("List(%s) returns %d entries, want %d", file, len(entries), len(names))
+		}
+		for i, name := range names {
+			if entries[i].Name != name {
+				t.Errorf("entries[%d]=%s, want %s", i, entries[i].Name, name)
+			}
+		}
+		if list := ReadDir(entries, "a"); len(list) != 3 || list[0].Name != "a/b" {
+			t.Errorf("ReadDir(a)=%v", list)
+		}
+		if e, ok := Lookup(entries, "a/b"); !ok || !e.Mode.IsDir() {
+			t.Errorf("Lookup(a/b)=%v, %v", e, ok)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ListContext(ctx, tgz); err != context.Canceled {
+		t.Errorf("ListContext() canceled returns %v", err)
+	}
+
+	dst := filepath.Join(dir, "out")
+	x := &Extractor{}
+	if err := x.Extract(tgz, "a", []string{"a/b", "a/link"}, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dst, "b", "c.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("extracted b/c.txt: %q, %v", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "b/c.txt" {
+		t.Errorf("extracted link: %q, %v", link, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "top.txt")); !os.IsNotExist(err) {
+		t.Errorf("extracted not selected top.txt: %v", err)
+	}
+
+	// canceled files are removed
+	canceled := filepath.Join(dir, "canceled")
+	stop := &Extractor{Copy: func(e *Entry, w io.Writer, r io.Reader) (int64, error) {
+		w.Write([]byte("part"))
+		return 4, context.Canceled
+	}}
+	if err := stop.Extract(tgz, "", []string{"top.txt"}, canceled); err != context.Canceled {
+		t.Errorf("Extract() canceled returns %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(canceled, "top.txt")); !os.IsNotExist(err) {
+		t.Errorf("left canceled top.txt: %v", err)
+	}
+
+	all := filepath.Join(dir, "all")
+	if err := x.Extract(txz, "", nil, all); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(all, "a", "hard")); err != nil || string(data) != "top" {
+		t.Errorf("extracted hard link: %q, %v", data, err)
+	}
+	if stat, err := os.Stat(filepath.Join(all, "top.txt")); err != nil || stat.Mode().Perm() != 0600 ||
+		!stat.ModTime().Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("extracted top.txt stat: %v, %v", stat, err)
+	}
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	dir := t.TempDir()
+	for i, files := range [][]testFile{
+		{{name: "../evil", body: "x", mode: 0644, typ: tar.TypeReg}},
+		{
+			{name: "escape", link: "..", mode: 0777, typ: tar.TypeSymlink},
+			{name: "escape/evil", body: "x", mode: 0644, typ: tar.TypeReg},
+		},
+	} {
+		file := filepath.Join(dir, "unsafe.tar")
+		fd, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTar(t, fd, files)
+		fd.Close()
+		x := &Extractor{}
+		if err := x.Extract(file, "", nil, filepath.Join(dir, "out")); err == nil {
+			t.Errorf("case %d: extracted without errors", i)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+			t.Errorf("case %d: written outside: %v", i, err)
+		}
+	}
+}
+
+func TestZip(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.zip")
+	fd, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(fd)
+	w, err := zw.Create("dir/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("zipped")); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	fd.Close()
+
+	entries, err := List(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Name != "dir/file.txt" || entries[1].Size != 6 {
+		t.Fatalf("List(%s)=%v", file, entries)
+	}
+	dst := filepath.Join(dir, "out")
+	x := &Extractor{Resolve: func(e *Entry, target string) (string, error) { return "", nil }}
+	if err := x.Extract(file, "dir", []string{"dir/file.txt"}, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dst, "file.txt"), []byte("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Extract(file, "dir", []string{"dir/file.txt"}, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dst, "file.txt")); string(data) != "kept" {
+		t.Errorf("overwritten despite skipping: %q", data)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "file.txt"), []byte("data"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/file.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.zip", "a.tar", "a.tar.gz", "a.tar.xz"} {
+		file := filepath.Join(dir, name)
+		a := &Archiver{}
+		if err := a.Create(file, []string{src}); err != nil {
+			t.Fatal(err)
+		}
+		dst := filepath.Join(dir, "out-"+name)
+		x := &Extractor{}
+		if err := x.Extract(file, "", nil, dst); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dst, "src", "sub", "file.txt")
+		if data, err := ioutil.ReadFile(path); err != nil || string(data) != "data" {
+			t.Errorf("%s: extracted %q, %v", name, data, err)
+		}
+		if stat, err := os.Stat(path); err != nil || stat.Mode().Perm() != 0640 {
+			t.Errorf("%s: extracted mode %v, %v", name, stat, err)
+		}
+		if link, err := os.Readlink(filepath.Join(dst, "src", "link")); err != nil || link != "sub/file.txt" {
+			t.Errorf("%s: extracted link %q, %v", name, link, err)
+		}
+	}
+	a := &Archiver{}
+	if err := a.Create(filepath.Join(dir, "a.tar.bz2"), []string{src}); err == nil {
+		t.Error("created bzip2 without errors")
+	}
+}
@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Archiver creates archives of files.
+type Archiver struct {
+	// Copy writes contents of the file.  io.Copy is used if Copy is nil.
+	Copy func(fi os.FileInfo, dst io.Writer, src io.Reader) (int64, error)
+}
+
+// Create writes the files and directories recursively to the archive file in
+// the format by the extension.  Entry names are relative to the parent
+// directory of each path.  Symlinks are stored as symlinks.  The archive file
+// is removed if failed.  Creating bzip2 is not supported.
+func (a *Archiver) Create(file string, paths []string) (err error) {
+	f, ok := formatOf(file)
+	if !ok {
+		return fmt.Errorf("unsupported archive %s", file)
+	} else if f == formatTarBz2 {
+		return fmt.Errorf("creating bzip2 is not supported %s", file)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	fd, err := os.Create(abs)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := fd.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(abs)
+		}
+	}()
+
+	var w entryWriter
+	switch f {
+	case formatZip:
+		w = &zipWriter{zip.NewWriter(fd)}
+	case formatTar:
+		w = &tarWriter{tw: tar.NewWriter(fd)}
+	case formatTarGz:
+		gz := gzip.NewWriter(fd)
+		w = &tarWriter{tw: tar.NewWriter(gz), c: gz}
+	case formatTarXz:
+		xw, err := xz.NewWriter(fd)
+		if err != nil {
+			return err
+		}
+		w = &tarWriter{tw: tar.NewWriter(xw), c: xw}
+	}
+	for _, path := range paths {
+		if err := a.add(w, abs, path); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (a *Archiver) add(w entryWriter, archive, path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	parent := filepath.Dir(path)
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == archive { // not to archive the archive itself
+			return nil
+		}
+		rel, err := filepath.Rel(parent, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil // devices, fifos and sockets are not stored
+		}
+		dst, err := w.Create(name, fi, link)
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if a.Copy != nil {
+			_, err = a.Copy(fi, dst, src)
+		} else {
+			_, err = io.Copy(dst, src)
+		}
+		return err
+	})
+}
+
+// entryWriter writes entries to an archive.
+type entryWriter interface {
+	// Create writes the header and returns the writer for contents.
+	Create(name string, fi os.FileInfo, link string) (io.Writer, error)
+	Close() error
+}
+
+type tarWriter struct {
+	tw *tar.Writer
+	c  io.Closer // compressor or nil
+}
+
+func (w *tarWriter) Create(name string, fi os.FileInfo, link string) (io.Writer, error) {
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return w.tw, nil
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.c != nil {
+		return w.c.Close()
+	}
+	return nil
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) Create(name string, fi os.FileInfo, link string) (io.Writer, error) {
+	hdr, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	} else if fi.Mode().IsRegular() {
+		hdr.Method = zip.Deflate
+	}
+	dst, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if link != "" { // the symlink target is stored as contents
+		if _, err := io.Copy(dst, strings.NewReader(link)); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func (w *zipWriter) Close() error { return w.zw.Close() }
@@ -0,0 +1,189 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extractor extracts entries of archives to a directory.  Entries are never
+// written outside of the directory even if names or symlinks point outside.
+type Extractor struct {
+	// Resolve returns the path to write the entry if the target already
+	// exists, or "" to skip the entry.  Existing targets are overwritten if
+	// Resolve is nil.
+	Resolve func(e *Entry, target string) (string, error)
+	// Copy writes contents of the entry.  io.Copy is used if Copy is nil.
+	Copy func(e *Entry, dst io.Writer, src io.Reader) (int64, error)
+}
+
+// Extract writes entries of the names in the archive file and their children
+// to the directory dst, or all entries if names are nil.  Entries are written
+// to paths relative to the archive directory dir, a slash path or "" as the
+// archive root.
+func (x *Extractor) Extract(file, dir string, names []string, dst string) error {
+	root, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return err
+	}
+
+	dirs := []*Entry{}
+	dirTargets := []string{}
+	extracted := map[string]string{} // entry name -> written path for hard links
+	err = Walk(file, func(e *Entry, r io.Reader) error {
+		if !matchNames(e.Name, names) {
+			return nil
+		}
+		rel := e.Name
+		if dir != "" {
+			rel = strings.TrimPrefix(rel, dir+"/")
+		}
+		target := filepath.Join(root, filepath.FromSlash(rel))
+		if err := checkInside(root, target); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+		if err != nil {
+			return err
+		}
+		if err := checkInside(root, parent); err != nil {
+			return err
+		}
+
+		if e.Mode.IsDir() {
+			if err := os.MkdirAll(target, e.Mode.Perm()|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, e)
+			dirTargets = append(dirTargets, target)
+			return nil
+		}
+		if _, err := os.Lstat(target); err == nil && x.Resolve != nil {
+			if target, err = x.Resolve(e, target); err != nil {
+				return err
+			} else if target == "" {
+				return nil
+			}
+		}
+		if lstat, err := os.Lstat(target); err == nil && lstat.Mode()&os.ModeSymlink != 0 {
+			// not to write through an existing symlink
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case e.Mode&os.ModeSymlink != 0:
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Symlink(e.Linkname, target); err != nil {
+				return err
+			}
+			extracted[e.Name] = target
+			return nil
+		case e.Mode.IsRegular() && e.Linkname != "":
+			name, err := cleanName(e.Linkname)
+			if err != nil {
+				return err
+			}
+			src, ok := extracted[name]
+			if !ok {
+				return fmt.Errorf("%s: hard link target %s not extracted", e.Name, e.Linkname)
+			}
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Link(src, target); err != nil {
+				return err
+			}
+		case e.Mode.IsRegular():
+			if err := x.writeFile(target, e, r); err != nil {
+				return err
+			}
+		default: // devices, fifos and sockets are not extracted
+			return nil
+		}
+		extracted[e.Name] = target
+		return os.Chtimes(target, e.ModTime, e.ModTime)
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirTargets[i], dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *Extractor) writeFile(target string, e *Entry, r io.Reader) error {
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	if x.Copy != nil {
+		_, err = x.Copy(e, file, r)
+	} else {
+		_, err = io.Copy(file, r)
+	}
+	if err != nil {
+		file.Close()
+		_ = os.Remove(target) // not leave a half-written file
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(target)
+		return err
+	}
+	return os.Chmod(target, e.Mode.Perm())
+}
+
+// Select returns entries of the names and their children.
+func Select(entries []*Entry, names []string) []*Entry {
+	list := []*Entry{}
+	for _, e := range entries {
+		if matchNames(e.Name, names) {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// matchNames reports whether the entry name is one of the names or in the
+// directories of the names.  All names match if the names are nil.
+func matchNames(name string, names []string) bool {
+	if names == nil {
+		return true
+	}
+	for _, n := range names {
+		if name == n || strings.HasPrefix(name, n+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInside returns an error if the path is outside of the root directory.
+func checkInside(root, path string) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("unsafe path %s outside of %s", path, root)
+	}
+	return nil
+}
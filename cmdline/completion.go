@@ -24,6 +24,12 @@ func ConfigCompletion(config func(*Completion) widget.Keymap) {
 	completionKeymap = config
 }
 
+// workdirer is implemented by filers resolving relative names against the
+// directory instead of the process working directory.
+type workdirer interface {
+	Workdir() string
+}
+
 // NewCompletion creates a new completion list box.
 func NewCompletion(x, y, width, height int, cmdline *Cmdline) *Completion {
 	comp := &Completion{
@@ -31,12 +37,16 @@ func NewCompletion(x, y, width, height int, cmdline *Cmdline) *Completion {
 		cmdline: cmdline,
 	}
 
+	workdir := ""
+	if w, ok := cmdline.filer.(workdirer); ok {
+		workdir = w.Workdir()
+	}
 	parser := parseCmdline(cmdline)
 	var candidates []string
 	if cmdline.mode.String() == "shell" && parser.cmdname == "" {
-		candidates = append(parser.compCommands(), parser.compFiles()...)
+		candidates = append(parser.compCommands(), parser.compFiles(workdir)...)
 	} else {
-		candidates = parser.compFiles()
+		candidates = parser.compFiles(workdir)
 	}
 	for _, v := range candidates {
 		comp.AppendHighlightString(v, parser.current)
@@ -108,12 +118,17 @@ func isSep(b byte) bool {
 	return b == ' ' || b == ';' || b == '|' || b == '>' || b == '&'
 }
 
-func (p *parser) compFiles() (candidates []string) {
+// compFiles returns file names completing the current word relative to the
+// working directory, or the process working directory if "".
+func (p *parser) compFiles(workdir string) (candidates []string) {
 	candidates = make([]string, 0, 100)
 	dirname, file := filepath.Split(p.current)
 	if dirname == "" {
 		dirname = "."
 	}
+	if workdir != "" && !filepath.IsAbs(dirname) {
+		dirname = filepath.Join(workdir, dirname)
+	}
 	dir, err := os.Open(dirname)
 	if err != nil {
 		return candidates
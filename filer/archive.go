@@ -0,0 +1,234 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anmitsu/goful/archive"
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
+)
+
+// archiveReader reads entries of a directory in an archive file.  Entries are
+// listed in the background at first and again if the archive file is
+// modified.
+type archiveReader struct {
+	path    string     // archive file path
+	mu      sync.Mutex // guards the following fields used while loading
+	dir     string     // slash path in the archive or "" as the root
+	entries []*archive.Entry
+	modTime time.Time
+}
+
+func newArchiveReader(file string) *archiveReader {
+	return &archiveReader{path: file}
+}
+
+// list lists entries of the archive file unless listed and not modified.
+// Decompressing is canceled if the callback with nil reports not to continue.
+func (r *archiveReader) list(callback func(*FileStat) bool) error {
+	stat, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	listed := r.entries != nil && stat.ModTime().Equal(r.modTime)
+	r.mu.Unlock()
+	if listed {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type result struct {
+		entries []*archive.Entry
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		entries, err := archive.ListContext(ctx, r.path)
+		done <- result{entries, err}
+	}()
+	tick := time.NewTicker(loadingInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case res := <-done:
+			if res.err != nil {
+				return res.err
+			}
+			r.mu.Lock()
+			r.entries, r.modTime = res.entries, stat.ModTime()
+			r.mu.Unlock()
+			return nil
+		case <-tick.C:
+			if !callback(nil) {
+				return context.Canceled
+			}
+		}
+	}
+}
+
+func (r *archiveReader) String() string {
+	return fmt.Sprintf("Archive:(%s)", filepath.Base(r.path))
+}
+
+// Read entries directly in the archive directory.
+func (r *archiveReader) Read(_ vfs.FS, _ string, callback func(*FileStat) bool) {
+	if err := r.list(callback); err == context.Canceled {
+		return
+	} else if err != nil {
+		message.Error(err)
+	}
+	r.mu.Lock()
+	entries := archive.ReadDir(r.entries, r.dir)
+	r.mu.Unlock()
+	for _, e := range entries {
+		name := path.Base(e.Name)
+		if !showHiddens && strings.HasPrefix(name, ".") {
+			continue
+		}
+		info := e.Info()
+		fs := newFileStat(r.join(e.Name), name, info, info)
+		fs.source = r.path
+		if !callback(fs) {
+			return
+		}
+	}
+}
+
+// join returns the virtual path of the entry name under the archive path.
+func (r *archiveReader) join(name string) string {
+	return filepath.Join(r.path, filepath.FromSlash(name))
+}
+
+func (r *archiveReader) isDir(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := archive.Lookup(r.entries, name)
+	return ok && e.Mode.IsDir()
+}
+
+func (f *FileStat) readlink() string {
+	if e, ok := f.Sys().(*archive.Entry); ok {
+		return e.Linkname
+	}
+	link, _ := os.Readlink(f.Path())
+	return link
+}
+
+// EnterArchive enters the archive file on the cursor as a read-only virtual
+// directory.  Entries are listed in the background like directories.
+// Chdir("..") in the archive root and Reset return to the directory.
+func (d *Directory) EnterArchive() {
+	if d.IsArchive() {
+		message.Errorf("Cannot enter archives in the archive")
+		return
+	}
+	if !d.IsLocal() {
+		message.Errorf("Cannot enter archives on %s", d.fs)
+		return
+	}
+	file := d.File()
+	if !archive.IsArchive(file.Name()) {
+		message.Errorf("Not supported archive %s", file.Name())
+		return
+	}
+	r := newArchiveReader(file.Path())
+	if d.finder != nil {
+		d.finder.exitNotRead()
+	}
+	d.history[d.Path] = file.Name()
+	d.reader = r
+	d.chdirArchive("", func() { d.SetCursor(0) })
+}
+
+// IsArchive reports whether the directory lists entries of an archive.
+func (d *Directory) IsArchive() bool {
+	_, ok := d.reader.(*archiveReader)
+	return ok
+}
+
+// ArchiveDir returns the archive file path and the slash path of the listed
+// directory in the archive, or "" and "" if not in an archive.
+func (d *Directory) ArchiveDir() (string, string) {
+	if r, ok := d.reader.(*archiveReader); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.path, r.dir
+	}
+	return "", ""
+}
+
+func (d *Directory) chdirArchive(dir string, done func()) {
+	r := d.reader.(*archiveReader)
+	r.mu.Lock()
+	r.dir = dir
+	r.mu.Unlock()
+	d.SetTitle(util.AbbrPath(r.join(dir)))
+	d.read(done)
+}
+
+// leaveArchive returns to the directory containing the archive and sets the
+// cursor to the archive file.
+func (d *Directory) leaveArchive() {
+	r := d.reader.(*archiveReader)
+	d.reader = defaultReader(".")
+	d.SetTitle(d.pathTitle(d.Path))
+	d.read(func() {
+		d.SetCursorByName(filepath.Base(r.path))
+		d.SetOffsetCenteredCursor()
+	})
+}
+
+// chdirInArchive changes the directory in the archive by the relative path and
+// reports whether handled.  Paths upper than the archive root leave the
+// archive.
+func (d *Directory) chdirInArchive(p string) bool {
+	r, ok := d.reader.(*archiveReader)
+	if !ok || filepath.IsAbs(p) || strings.HasPrefix(p, "~") {
+		return false
+	}
+	if d.finder != nil {
+		d.finder.exitNotRead()
+	}
+	dir := path.Clean(path.Join("/", r.dir, filepath.ToSlash(p)))[1:]
+	if rel := path.Clean(path.Join(r.dir, filepath.ToSlash(p))); rel == ".." || strings.HasPrefix(rel, "../") {
+		d.leaveArchive()
+		if rel != ".." {
+			d.Chdir(filepath.FromSlash(strings.TrimPrefix(rel, "../")))
+		}
+		return true
+	}
+	if dir != "" && !r.isDir(dir) {
+		message.Errorf("%s: not a directory in the archive", dir)
+		return true
+	}
+	if !d.IsEmpty() {
+		d.history[r.join(r.dir)] = d.File().Name()
+	}
+	prev := r.dir
+	parent := path.Dir(prev)
+	if parent == "." {
+		parent = ""
+	}
+	d.chdirArchive(dir, func() {
+		if name, ok := d.history[r.join(dir)]; ok {
+			d.SetCursorByName(name)
+			d.SetOffsetCenteredCursor()
+		} else if prev != "" && dir == parent {
+			d.SetCursorByName(path.Base(prev))
+			d.SetOffsetCenteredCursor()
+		} else {
+			d.SetCursor(0)
+		}
+	})
+	return true
+}
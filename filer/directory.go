@@ -1,27 +1,38 @@
 package filer
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/anmitsu/goful/look"
 	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/trash"
 	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
 	"github.com/anmitsu/goful/widget"
 )
 
 // Directory is a list box to store file stats.
 type Directory struct {
 	*widget.ListBox
-	reader  reader
-	history map[string]string // key: path, value: file name on cursor
-	finder  *Finder
-	Path    string   `json:"path"`
-	Sort    sortType `json:"sort_kind"`
+	reader     reader
+	fs         vfs.FS            // file system of the path
+	localPath  string            // local path to return from other file systems
+	history    map[string]string // key: path, value: file name on cursor
+	finder     *Finder
+	sizeCancel context.CancelFunc // cancels calculations of directory sizes
+	loading    *loading           // non-nil while reading in the background
+	listed     string             // key of the listed directory and reader
+	Path       string             `json:"path"`
+	Sort       sortType           `json:"sort_kind"`
 }
 
 // NewDirectory creates a new directory based on specified size and coordinates.
@@ -32,6 +43,7 @@ func NewDirectory(x, y, width, height int) *Directory {
 	return &Directory{
 		ListBox: listbox,
 		reader:  defaultReader("."),
+		fs:      vfs.Local,
 		history: map[string]string{},
 		Path:    path,
 		Sort:    sortName,
@@ -74,37 +86,27 @@ func ToggleShowHiddens() {
 	showHiddens = !showHiddens
 }
 
+// reader lists files of the directory path in the file system calling the
+// callback for each file until the callback returns false.  The callback with
+// nil only reports whether to continue reading.
 type reader interface {
-	Read(callback func(name string))
+	Read(fsys vfs.FS, dir string, callback func(fs *FileStat) bool)
 	String() string
 }
 
 type defaultReader string
 
 func (s defaultReader) String() string { return "" }
-func (s defaultReader) Read(callback func(string)) {
-	fd, err := os.Open(string(s))
+func (s defaultReader) Read(fsys vfs.FS, dir string, callback func(*FileStat) bool) {
+	err := fsys.ReadDir(dir, func(fi os.FileInfo) bool {
+		name := fi.Name()
+		if !showHiddens && strings.HasPrefix(name, ".") {
+			return true
+		}
+		return callback(newFileStatInfo(fsys, filepath.Join(dir, name), name, fi))
+	})
 	if err != nil {
 		message.Error(err)
-		return
-	}
-	defer fd.Close()
-
-	for {
-		names, err := fd.Readdirnames(100)
-		for _, name := range names {
-			if !showHiddens && strings.HasPrefix(name, ".") {
-				continue
-			}
-			callback(name)
-		}
-
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			message.Error(err)
-			return
-		}
 	}
 }
 
@@ -114,17 +116,25 @@ func (s globPattern) String() string {
 	return fmt.Sprintf("Glob:(%s)", string(s))
 }
 
-func (s globPattern) Read(callback func(name string)) {
-	matches, err := filepath.Glob(string(s))
+func (s globPattern) Read(fsys vfs.FS, dir string, callback func(*FileStat) bool) {
+	matches, err := vfs.Glob(fsys, filepath.Join(dir, string(s)))
 	if err != nil {
 		message.Error(err)
 		return
 	}
-	for _, name := range matches {
+	for _, match := range matches {
+		name, err := filepath.Rel(dir, match)
+		if err != nil {
+			continue
+		}
 		if !showHiddens && strings.HasPrefix(name, ".") {
 			continue
 		}
-		callback(name)
+		if fs := NewFileStatFS(fsys, dir, name); fs != nil {
+			if !callback(fs) {
+				return
+			}
+		}
 	}
 }
 
@@ -134,29 +144,78 @@ func (s globDirPattern) String() string {
 	return fmt.Sprintf("Globdir:(%s)", string(s))
 }
 
-func (s globDirPattern) Read(callback func(string)) {
-	_ = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if path == "." {
+func (s globDirPattern) Read(fsys vfs.FS, dir string, callback func(*FileStat) bool) {
+	_ = vfs.Walk(fsys, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
 			return nil
 		}
 		if ok, _ := filepath.Match(string(s), info.Name()); ok {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil
+			}
 			if !showHiddens {
-				if strings.HasPrefix(path, ".") || strings.HasPrefix(info.Name(), ".") {
+				if strings.HasPrefix(rel, ".") || strings.HasPrefix(info.Name(), ".") {
 					return nil
 				}
 			}
-			callback(path)
+			if !callback(newFileStatInfo(fsys, path, rel, info)) {
+				return io.EOF // stop walking
+			}
 		}
 		return nil
 	})
 }
 
+type trashReader struct{}
+
+func (trashReader) String() string { return "Trash" }
+
+// Read trashed files displayed by original paths and deletion dates as the
+// modification time.  The trash is always on the local disk.
+func (trashReader) Read(_ vfs.FS, dir string, callback func(*FileStat) bool) {
+	entries, err := trash.List()
+	if err != nil {
+		message.Error(err)
+	}
+	for _, e := range entries {
+		file := e.File()
+		lstat, err := os.Lstat(file)
+		if err != nil {
+			continue
+		}
+		stat, err := os.Stat(file)
+		if err != nil {
+			stat = lstat
+		}
+		fs := newFileStat(file, file, trashedInfo{lstat, e.Deleted}, trashedInfo{stat, e.Deleted})
+		if stat.IsDir() {
+			fs.SetDisplay(e.Path)
+		} else {
+			fs.SetDisplay(util.RemoveExt(e.Path))
+		}
+		if !callback(fs) {
+			return
+		}
+	}
+}
+
+// trashedInfo is a trashed file info returning the deletion date as the
+// modification time.
+type trashedInfo struct {
+	os.FileInfo
+	deleted time.Time
+}
+
+func (t trashedInfo) ModTime() time.Time { return t.deleted }
+
 func (d *Directory) init4json() {
 	d.ListBox = widget.NewListBox(0, 0, 0, 0, "")
 	d.history = map[string]string{}
 	d.SetTitle(util.AbbrPath(d.Path))
 	d.SetColumn(1)
 	d.reader = defaultReader(".")
+	d.fs = vfs.Local
 }
 
 // Resize the window and the finder.
@@ -184,18 +243,26 @@ func (d *Directory) EnterDir() {
 func (d *Directory) Reset() {
 	if d.IsMark() {
 		d.MarkClear()
+	} else if d.IsArchive() {
+		d.leaveArchive()
 	} else if _, ok := d.reader.(defaultReader); !ok {
 		name := d.File().Name()
 		d.reader = defaultReader(".")
-		d.read()
-		d.SetCursorByName(name)
-		d.SetOffsetCenteredCursor()
+		d.read(func() {
+			d.SetCursorByName(name)
+			d.SetOffsetCenteredCursor()
+		})
+	} else if !d.IsLocal() {
+		d.Unmount()
 	}
 }
 
 // Chdir changes the current directory and reads a new path by the default reader.
 // Sets the cursor to the history name or to the previous directory name if parent destinats.
 func (d *Directory) Chdir(path string) {
+	if d.chdirInArchive(path) {
+		return
+	}
 	path = util.ExpandPath(path)
 	path = filepath.Clean(path)
 	if !filepath.IsAbs(path) {
@@ -208,42 +275,164 @@ func (d *Directory) Chdir(path string) {
 		d.finder.exitNotRead()
 	}
 
-	if err := os.Chdir(path); err != nil {
+	if err := d.enter(path); err != nil {
 		message.Error(err)
 		return
 	}
 	if !d.IsEmpty() {
 		d.history[d.Path] = d.File().Name()
 	}
-	d.SetTitle(util.AbbrPath(path))
+	d.SetTitle(d.pathTitle(path))
 	d.Path = path
 	d.reader = defaultReader(".")
-	d.read()
-
-	if name, ok := d.history[d.Path]; ok {
-		d.SetCursorByName(name)
-		d.SetOffsetCenteredCursor()
-	} else if path == parent {
-		d.SetCursorByName(olddir)
-		d.SetOffsetCenteredCursor()
-	} else {
-		d.SetCursor(0)
+	d.read(func() {
+		if name, ok := d.history[path]; ok {
+			d.SetCursorByName(name)
+			d.SetOffsetCenteredCursor()
+		} else if path == parent {
+			d.SetCursorByName(olddir)
+			d.SetOffsetCenteredCursor()
+		} else {
+			d.SetCursor(0)
+		}
+	})
+}
+
+// enter checks the path is a directory to enter.  The process working
+// directory is not changed, and names are resolved against the directory path.
+func (d *Directory) enter(path string) error {
+	fi, err := d.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &os.PathError{Op: "chdir", Path: path, Err: errNotDir}
+	}
+	return nil
+}
+
+var errNotDir = errors.New("not a directory")
+
+// fallback changes the directory to the home directory on the local disk if
+// failed to enter the path.
+func (d *Directory) fallback() {
+	d.fs = vfs.Local
+	home, _ := os.UserHomeDir()
+	d.Chdir(home)
+}
+
+// FS returns the file system of the directory.
+func (d *Directory) FS() vfs.FS { return d.fs }
+
+// IsLocal reports whether the directory is on the local disk.
+func (d *Directory) IsLocal() bool { return vfs.IsLocal(d.fs) }
+
+// Mount changes the file system of the directory and lists the path in it.
+func (d *Directory) Mount(fsys vfs.FS, path string) {
+	if fi, err := fsys.Stat(path); err != nil {
+		message.Error(err)
+		return
+	} else if !fi.IsDir() {
+		message.Error(&os.PathError{Op: "mount", Path: path, Err: errNotDir})
+		return
+	}
+	if d.IsArchive() {
+		d.reader = defaultReader(".")
 	}
+	if !d.IsEmpty() {
+		d.history[d.Path] = d.File().Name()
+	}
+	if d.IsLocal() {
+		d.localPath = d.Path
+	}
+	d.fs = fsys
+	d.Chdir(path)
+}
+
+// Unmount returns to the local path mounted from other file systems.
+func (d *Directory) Unmount() {
+	if d.IsLocal() {
+		return
+	}
+	d.fs = vfs.Local
+	if d.localPath == "" {
+		d.fallback()
+		return
+	}
+	d.Chdir(d.localPath)
+	if d.Path != d.localPath { // failed to change
+		d.fallback()
+	}
+}
+
+// directoryState is the directory state saved to the file.
+type directoryState struct {
+	Path string   `json:"path"`
+	Sort sortType `json:"sort_kind"`
+}
+
+// MarshalJSON saves the local path mounted from instead of the path on other
+// file systems not restored.
+func (d *Directory) MarshalJSON() ([]byte, error) {
+	path := d.Path
+	if !d.IsLocal() && d.localPath != "" {
+		path = d.localPath
+	}
+	return json.Marshal(directoryState{path, d.Sort})
+}
+
+// pathTitle returns the title of the path prefixed by the file system name
+// unless the local disk.
+func (d *Directory) pathTitle(path string) string {
+	if d.IsLocal() {
+		return util.AbbrPath(path)
+	}
+	return d.fs.String() + filepath.ToSlash(path)
 }
 
 // Glob sets a reader to matching pattern in the current directory.
 func (d *Directory) Glob(pattern string) {
-	d.reader = globPattern(pattern)
-	d.read()
+	d.setReader(globPattern(pattern))
+	d.read(nil)
 }
 
 // Globdir sets a reader to matching pattern in the directory includeing sub directories.
 func (d *Directory) Globdir(pattern string) {
-	d.reader = globDirPattern(pattern)
-	d.read()
+	d.setReader(globDirPattern(pattern))
+	d.read(nil)
+}
+
+// setReader sets the reader for the directory path, leaving an archive.
+func (d *Directory) setReader(r reader) {
+	if d.IsArchive() {
+		d.SetTitle(d.pathTitle(d.Path))
+	}
+	d.reader = r
+}
+
+// Trash sets a reader to list trashed files.  Reset returns to the directory.
+func (d *Directory) Trash() {
+	if !d.IsLocal() {
+		message.Errorf("Cannot list the trash on %s", d.fs)
+		return
+	}
+	d.setReader(trashReader{})
+	d.read(func() { d.SetCursor(0) })
 }
 
-func (d *Directory) read() {
+// IsTrash reports whether the directory lists trashed files.
+func (d *Directory) IsTrash() bool {
+	_, ok := d.reader.(trashReader)
+	return ok
+}
+
+// read lists files by the reader keeping marks, and calls done after sorting
+// or keeps the cursor file if done is nil.  Files are read in the background
+// if the sync callback is set.
+func (d *Directory) read(done func()) {
+	if d.finder == nil { // keeps loading files filtered by the finder
+		d.cancelLoading()
+	}
 	marked := make(map[string]bool, d.MarkCount())
 	for _, e := range d.List() {
 		if e.(*FileStat).IsMarked() {
@@ -251,37 +440,53 @@ func (d *Directory) read() {
 		}
 	}
 
-	callback := func(name string) {
-		if fs := NewFileStat(d.Path, name); fs != nil {
-			d.AppendList(fs)
+	finish := func(cursor string) {
+		if d.IsEmpty() {
+			d.AppendList(NewFileStatFS(d.fs, d.Path, ".."))
+		}
+		if d.finder == nil || !d.finder.fuzzy { // fuzzy finders rank files
+			sort.Sort(d)
 		}
-	}
-	if d.finder != nil {
-		d.finder.find(callback)
-	} else {
-		d.ClearList()
-		d.reader.Read(callback)
-	}
-	if d.IsEmpty() {
-		d.AppendList(NewFileStat(d.Path, ".."))
-	}
-	sort.Sort(d)
 
-	for _, e := range d.List() {
-		if _, ok := marked[e.(*FileStat).Path()]; ok {
-			e.(*FileStat).Mark()
+		for _, e := range d.List() {
+			if _, ok := marked[e.(*FileStat).Path()]; ok {
+				e.(*FileStat).Mark()
+			}
 		}
+		if done != nil {
+			done()
+		} else if i := d.IndexByName(cursor); cursor != "" && d.List()[i].Name() == cursor {
+			d.SetCursor(i)
+		}
+		d.calcDirSizes()
+	}
+
+	switch {
+	case d.finder != nil:
+		d.finder.find()
+		finish("")
+	case syncCallback == nil:
+		d.ClearList()
+		d.reader.Read(d.fs, d.Path, func(fs *FileStat) bool {
+			if fs != nil {
+				d.AppendList(fs)
+			}
+			return true
+		})
+		d.listed = d.listKey()
+		finish("")
+	default:
+		d.load(finish)
 	}
 }
 
-func (d *Directory) reload() {
-	if err := os.Chdir(d.Path); err != nil {
+func (d *Directory) reload(done func()) {
+	if err := d.enter(d.Path); err != nil {
 		message.Error(err)
-		home, _ := os.UserHomeDir()
-		d.Chdir(home)
+		d.fallback()
 		return
 	}
-	d.read()
+	d.read(done)
 }
 
 // File returns a file on the cursor.
@@ -326,6 +531,9 @@ func (d *Directory) SortExtDec() { d.sortBy(sortExtRev) }
 
 // Less compares based on Sort.
 func (d *Directory) Less(i, j int) bool {
+	if fi, fj := d.List()[i].(*FileStat), d.List()[j].(*FileStat); fi.line > 0 && fi.path == fj.path {
+		return fi.line < fj.line // grep matches in the file
+	}
 	if priorityDir {
 		id := d.List()[i].(*FileStat).stat.IsDir()
 		jd := d.List()[j].(*FileStat).stat.IsDir()
@@ -335,9 +543,9 @@ func (d *Directory) Less(i, j int) bool {
 	}
 	switch d.Sort {
 	case sortName:
-		return d.List()[i].Name() < d.List()[j].Name()
+		return d.lessName(i, j)
 	case sortNameRev:
-		return d.List()[i].Name() > d.List()[j].Name()
+		return d.lessName(j, i)
 	case sortMtime:
 		return d.lessMtime(i, j)
 	case sortMtimeRev:
@@ -351,7 +559,18 @@ func (d *Directory) Less(i, j int) bool {
 	case sortExtRev:
 		return d.lessExt(j, i)
 	}
-	return d.List()[i].Name() < d.List()[j].Name()
+	return d.lessName(i, j)
+}
+
+// lessName compares names, or paths of lines listed by grep and of the same
+// names in other directories.
+func (d *Directory) lessName(i, j int) bool {
+	f1 := d.List()[i].(*FileStat)
+	f2 := d.List()[j].(*FileStat)
+	if f1.line > 0 && f2.line > 0 || f1.Name() == f2.Name() {
+		return f1.Path() < f2.Path()
+	}
+	return f1.Name() < f2.Name()
 }
 
 func (d *Directory) lessMtime(i, j int) bool {
@@ -362,7 +581,7 @@ func (d *Directory) lessMtime(i, j int) bool {
 	if t1 != t2 {
 		return t1 < t2
 	}
-	return f1.Name() < f2.Name()
+	return d.lessName(i, j)
 }
 
 func (d *Directory) lessSize(i, j int) bool {
@@ -373,7 +592,7 @@ func (d *Directory) lessSize(i, j int) bool {
 	if s1 != s2 {
 		return s1 < s2
 	}
-	return f1.Name() < f2.Name()
+	return d.lessName(i, j)
 }
 
 func (d *Directory) lessExt(i, j int) bool {
@@ -384,7 +603,7 @@ func (d *Directory) lessExt(i, j int) bool {
 	if e1 != e2 {
 		return e1 < e2
 	}
-	return f1.Name() < f2.Name()
+	return d.lessName(i, j)
 }
 
 // IsMark reports whether even one file marked.
@@ -430,15 +649,19 @@ func (d *Directory) MarkCount() int {
 	return c
 }
 
-// Markfiles returns marked file lists.
+// Markfiles returns marked file lists.  Files marked in several rows such as
+// lines listed by grep are returned once.
 func (d *Directory) Markfiles() []*FileStat {
 	if d.MarkCount() < 1 {
 		return []*FileStat{d.File()}
 	}
 	markfiles := make([]*FileStat, 0, d.MarkCount())
+	seen := map[string]bool{}
 	for _, e := range d.List() {
-		if e.(*FileStat).IsMarked() {
-			markfiles = append(markfiles, e.(*FileStat))
+		fs := e.(*FileStat)
+		if fs.IsMarked() && !seen[fs.Path()] {
+			seen[fs.Path()] = true
+			markfiles = append(markfiles, fs)
 		}
 	}
 	return markfiles
@@ -446,56 +669,36 @@ func (d *Directory) Markfiles() []*FileStat {
 
 // MarkfileNames returns marked file names.
 func (d *Directory) MarkfileNames() []string {
-	if d.MarkCount() < 1 {
-		return []string{d.File().Name()}
-	}
-	markfiles := make([]string, 0, d.MarkCount())
-	for _, e := range d.List() {
-		if e.(*FileStat).IsMarked() {
-			markfiles = append(markfiles, e.(*FileStat).Name())
-		}
+	markfiles := []string{}
+	for _, fs := range d.Markfiles() {
+		markfiles = append(markfiles, fs.Name())
 	}
 	return markfiles
 }
 
 // MarkfilePaths returns marked file paths.
 func (d *Directory) MarkfilePaths() []string {
-	if d.MarkCount() < 1 {
-		return []string{d.File().Path()}
-	}
-	markfiles := make([]string, 0, d.MarkCount())
-	for _, e := range d.List() {
-		if e.(*FileStat).IsMarked() {
-			markfiles = append(markfiles, e.(*FileStat).Path())
-		}
+	markfiles := []string{}
+	for _, fs := range d.Markfiles() {
+		markfiles = append(markfiles, fs.Path())
 	}
 	return markfiles
 }
 
 // MarkfileQuotedNames returns quoted file names for marked.
 func (d *Directory) MarkfileQuotedNames() []string {
-	if d.MarkCount() < 1 {
-		return []string{util.Quote(d.File().Name())}
-	}
-	markfiles := make([]string, 0, d.MarkCount())
-	for _, e := range d.List() {
-		if e.(*FileStat).IsMarked() {
-			markfiles = append(markfiles, util.Quote(e.(*FileStat).Name()))
-		}
+	markfiles := []string{}
+	for _, fs := range d.Markfiles() {
+		markfiles = append(markfiles, util.Quote(fs.Name()))
 	}
 	return markfiles
 }
 
 // MarkfileQuotedPaths returns quoted file paths for marked.
 func (d *Directory) MarkfileQuotedPaths() []string {
-	if d.MarkCount() < 1 {
-		return []string{util.Quote(d.File().Path())}
-	}
-	markfiles := make([]string, 0, d.MarkCount())
-	for _, e := range d.List() {
-		if e.(*FileStat).IsMarked() {
-			markfiles = append(markfiles, util.Quote(e.(*FileStat).Path()))
-		}
+	markfiles := []string{}
+	for _, fs := range d.Markfiles() {
+		markfiles = append(markfiles, util.Quote(fs.Path()))
 	}
 	return markfiles
 }
@@ -503,6 +706,9 @@ func (d *Directory) MarkfileQuotedPaths() []string {
 func (d *Directory) drawFooter() {
 	s := fmt.Sprintf("[%d/%d] %s(%d) %s %s",
 		d.MarkCount(), len(d.List()), d.ScrollRate(), d.Cursor(), d.Sort, d.reader.String())
+	if d.loading != nil {
+		s += fmt.Sprintf(" loading %d entries", d.loading.loaded())
+	}
 	x, y := d.LeftBottom()
 	widget.SetCells(x, y, s, look.Default())
 }
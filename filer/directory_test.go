@@ -0,0 +1,42 @@
+package filer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/anmitsu/goful/vfs"
+)
+
+func TestMount(t *testing.T) {
+	mem := vfs.NewMem()
+	for _, name := range []string{"/home/b.txt", "/home/sub/c.txt", "/home/a.txt"} {
+		if err := mem.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, _ := os.Getwd()
+	d := NewDirectory(0, 0, 80, 20)
+	d.Mount(mem, "/home")
+	if d.FS() != mem || d.Path != "/home" || d.Title() != "mem:/home" {
+		t.Fatalf("mounted %s%s titled %s", d.FS(), d.Path, d.Title())
+	}
+	names := []string{}
+	for _, e := range d.List() {
+		names = append(names, e.Name())
+	}
+	if len(names) != 3 || names[0] != "sub" || names[1] != "a.txt" || names[2] != "b.txt" {
+		t.Errorf("listed %q", names)
+	}
+	if cwd, _ := os.Getwd(); cwd != wd {
+		t.Errorf("changed the working directory to %s", cwd)
+	}
+
+	d.EnterDir()
+	if d.Path != "/home/sub" || d.File().Name() != "c.txt" {
+		t.Errorf("entered %s at %s", d.Path, d.File().Name())
+	}
+	d.Chdir("..")
+	if d.Path != "/home" || d.File().Name() != "sub" {
+		t.Errorf("returned to %s at %s", d.Path, d.File().Name())
+	}
+}
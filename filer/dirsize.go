@@ -0,0 +1,146 @@
+package filer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/anmitsu/goful/util"
+)
+
+var dirSizeView = false
+
+// ToggleDirSizeView toggles the view of recursive directory sizes instead of
+// <DIR>.  Sizes are calculated in the background and cached.
+func ToggleDirSizeView() { dirSizeView = !dirSizeView }
+
+var redraw = func() {}
+
+// SetRedraw sets the function to redraw called from background goroutines
+// after directory sizes are calculated.
+func SetRedraw(f func()) { redraw = f }
+
+// dirSize is a cached total size and file count of a directory.
+type dirSize struct {
+	size    int64
+	count   int
+	modTime time.Time // of the directory when calculated
+}
+
+var dirSizes = struct {
+	sync.Mutex
+	cache map[string]dirSize
+}{cache: map[string]dirSize{}}
+
+// dirSizesMax is the maximum number of cached directory sizes.
+const dirSizesMax = 10000
+
+// sizeRequest is a directory queued to calculate the size.
+type sizeRequest struct {
+	ctx     context.Context
+	path    string
+	modTime time.Time
+}
+
+const sizeWorkers = 4 // calculating directory sizes concurrently
+
+var (
+	sizeQueue     = make(chan sizeRequest)
+	sizeQueueOnce sync.Once
+)
+
+// startSizeWorkers starts the fixed workers calculating directory sizes
+// queued to sizeQueue.
+func startSizeWorkers() {
+	for i := 0; i < sizeWorkers; i++ {
+		go func() {
+			for req := range sizeQueue {
+				if req.ctx.Err() != nil {
+					continue
+				}
+				if _, _, err := CalcDirSize(req.ctx, req.path, req.modTime); err == nil {
+					redraw()
+				}
+			}
+		}()
+	}
+}
+
+// cachedDirSize returns the cached size of the directory if calculated after
+// the last modification.  Changes deep in the directory are not detected until
+// the cache is cleared by ClearDirSizes.
+func cachedDirSize(path string, modTime time.Time) (dirSize, bool) {
+	dirSizes.Lock()
+	defer dirSizes.Unlock()
+	ds, ok := dirSizes.cache[path]
+	return ds, ok && ds.modTime.Equal(modTime)
+}
+
+// ClearDirSizes clears cached directory sizes.
+func ClearDirSizes() {
+	dirSizes.Lock()
+	dirSizes.cache = map[string]dirSize{}
+	dirSizes.Unlock()
+}
+
+// CalcDirSize calculates the total size and the file count of the directory
+// using the cache.  Canceling the context stops the calculation and returns
+// the context error.
+func CalcDirSize(ctx context.Context, path string, modTime time.Time) (int64, int, error) {
+	if ds, ok := cachedDirSize(path, modTime); ok {
+		return ds.size, ds.count, nil
+	}
+	size, count, err := util.CalcSizeCountContext(ctx, path)
+	if err != nil {
+		return size, count, err
+	}
+	dirSizes.Lock()
+	if _, ok := dirSizes.cache[path]; !ok && len(dirSizes.cache) >= dirSizesMax {
+		for p := range dirSizes.cache { // evict an arbitrary entry
+			delete(dirSizes.cache, p)
+			break
+		}
+	}
+	dirSizes.cache[path] = dirSize{size, count, modTime}
+	dirSizes.Unlock()
+	return size, count, nil
+}
+
+// calcDirSizes calculates sizes of listed directories in the background until
+// the directory is read again.
+func (d *Directory) calcDirSizes() {
+	if d.sizeCancel != nil {
+		d.sizeCancel()
+		d.sizeCancel = nil
+	}
+	if !dirSizeView || !d.IsLocal() {
+		return
+	}
+	reqs := []sizeRequest{}
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, e := range d.List() {
+		f := e.(*FileStat)
+		if !f.IsDir() || f.Name() == ".." || f.source != "" {
+			continue
+		}
+		if _, ok := cachedDirSize(f.Path(), f.ModTime()); ok {
+			continue
+		}
+		reqs = append(reqs, sizeRequest{ctx, f.Path(), f.ModTime()})
+	}
+	if len(reqs) < 1 {
+		cancel()
+		return
+	}
+	d.sizeCancel = cancel
+	sizeQueueOnce.Do(startSizeWorkers)
+	go func() {
+		for _, req := range reqs {
+			select {
+			case sizeQueue <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,45 @@
+package filer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCalcDirSizes(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		sub := filepath.Join(dir, name)
+		if err := os.Mkdir(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(sub, "file"), make([]byte, i), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirSizeView = true
+	defer func() { dirSizeView = false }()
+	defer ClearDirSizes()
+
+	d := NewDirectory(0, 0, 80, 20)
+	d.Chdir(dir)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, e := range d.List() {
+		f := e.(*FileStat)
+		for {
+			ds, ok := cachedDirSize(f.Path(), f.ModTime())
+			if ok {
+				if want := int64(f.Name()[0] - 'a'); ds.size != want || ds.count != 1 {
+					t.Errorf("%s is %d bytes in %d files, want %d bytes", f.Name(), ds.size, ds.count, want)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("size of %s is not calculated", f.Name())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/anmitsu/goful/look"
 	"github.com/anmitsu/goful/message"
 	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
 	"github.com/anmitsu/goful/widget"
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
@@ -48,22 +50,41 @@ type FileStat struct {
 	name        string      // base name of path or ".." as upper directory
 	display     string      // display name for draw
 	marked      bool        // marked whether
+	source      string      // archive file containing the file or "" for the file system
+	matches     []int       // rune indices of the name matched by the finder, or of the display by grep
+	line        int         // line number of the grep match or 0
 }
 
 // NewFileStat creates a new file stat of the file in the directory.
 func NewFileStat(dir string, name string) *FileStat {
+	return NewFileStatFS(vfs.Local, dir, name)
+}
+
+// NewFileStatFS creates a new file stat of the file in the directory of the
+// file system.
+func NewFileStatFS(fsys vfs.FS, dir string, name string) *FileStat {
 	path := filepath.Join(dir, name)
 
-	lstat, err := os.Lstat(path)
+	lstat, err := fsys.Lstat(path)
 	if err != nil {
 		message.Error(err)
 		return nil
 	}
-	stat, err := os.Stat(path)
-	if err != nil {
-		stat = lstat
+	return newFileStatInfo(fsys, path, name, lstat)
+}
+
+// newFileStatInfo creates a new file stat from the lstat following the symlink.
+func newFileStatInfo(fsys vfs.FS, path, name string, lstat os.FileInfo) *FileStat {
+	stat := lstat
+	if lstat.Mode()&os.ModeSymlink != 0 {
+		if s, err := fsys.Stat(path); err == nil {
+			stat = s
+		}
 	}
+	return newFileStat(path, name, lstat, stat)
+}
 
+func newFileStat(path, name string, lstat, stat os.FileInfo) *FileStat {
 	var display string
 	if stat.IsDir() {
 		display = name
@@ -86,6 +107,15 @@ func (f *FileStat) Name() string {
 	return f.name
 }
 
+// findName returns the name filtered by the finder, which is the display of
+// lines listed by grep.
+func (f *FileStat) findName() string {
+	if f.line > 0 {
+		return f.display
+	}
+	return f.name
+}
+
 // SetDisplay sets the display name for drawing.
 func (f *FileStat) SetDisplay(name string) {
 	f.display = name
@@ -125,12 +155,22 @@ func (f *FileStat) Ext() string {
 	if f.stat.IsDir() {
 		return ""
 	}
-	if ext := filepath.Ext(f.Name()); ext != f.Name() {
+	name := f.Name()
+	if f.line > 0 { // the name has the line
+		name = filepath.Base(f.path)
+	}
+	if ext := filepath.Ext(name); ext != name {
 		return ext
 	}
 	return ""
 }
 
+// Source returns the archive file path containing the file or "" if the file
+// is on the file system.
+func (f *FileStat) Source() string {
+	return f.source
+}
+
 // IsLink reports whether the symlink.
 func (f *FileStat) IsLink() bool {
 	return f.Mode()&os.ModeSymlink != 0
@@ -168,7 +208,7 @@ func (f *FileStat) IsMarked() bool {
 
 func (f *FileStat) suffix() string {
 	if f.IsLink() {
-		link, _ := os.Readlink(f.Path())
+		link := f.readlink()
 		if f.stat.IsDir() {
 			return "@ -> " + link + "/"
 		}
@@ -189,7 +229,7 @@ func (f *FileStat) states() string {
 	ret := f.Ext()
 	if statView.size {
 		if f.stat.IsDir() {
-			ret += fmt.Sprintf("%8s", "<DIR>")
+			ret += fmt.Sprintf("%8s", f.dirSize())
 		} else {
 			ret += fmt.Sprintf("%8s", util.FormatSize(f.stat.Size()))
 		}
@@ -203,6 +243,16 @@ func (f *FileStat) states() string {
 	return ret
 }
 
+// dirSize returns the calculated size of the directory or <DIR>.
+func (f *FileStat) dirSize() string {
+	if dirSizeView && f.IsDir() {
+		if ds, ok := cachedDirSize(f.path, f.ModTime()); ok {
+			return util.FormatSize(ds.size)
+		}
+	}
+	return "<DIR>"
+}
+
 func (f *FileStat) look() tcell.Style {
 	switch {
 	case f.IsMarked():
@@ -234,8 +284,47 @@ func (f *FileStat) Draw(x, y, width int, focus bool) {
 		pre = "*"
 	}
 	s := pre + f.display + f.suffix()
-	s = runewidth.Truncate(s, width, "~")
-	s = runewidth.FillRight(s, width)
-	x = widget.SetCells(x, y, s, style)
-	widget.SetCells(x, y, states, style)
+	if len(f.matches) == 0 {
+		s = runewidth.Truncate(s, width, "~")
+		s = runewidth.FillRight(s, width)
+		x = widget.SetCells(x, y, s, style)
+		widget.SetCells(x, y, states, style)
+		return
+	}
+
+	// highlight matches in the display name and the extension of states
+	hstyle := look.Highlight()
+	if focus {
+		hstyle = hstyle.Reverse(true)
+	}
+	names, exts := map[int]bool{}, map[int]bool{}
+	display := 0
+	if f.line > 0 || strings.HasPrefix(f.name, f.display) {
+		display = len([]rune(f.display))
+	}
+	ext := len([]rune(f.name)) - len([]rune(f.Ext()))
+	for _, i := range f.matches {
+		if i < display {
+			names[len(pre)+i] = true
+		} else if i >= ext {
+			exts[i-ext] = true
+		}
+	}
+	t := runewidth.Truncate(s, width, "~")
+	t = runewidth.FillRight(t, width)
+	orig := []rune(s)
+	for i, r := range []rune(t) {
+		if names[i] && i < len(orig) && r == orig[i] {
+			x = widget.SetCells(x, y, string(r), hstyle)
+		} else {
+			x = widget.SetCells(x, y, string(r), style)
+		}
+	}
+	for i, r := range []rune(states) {
+		if exts[i] {
+			x = widget.SetCells(x, y, string(r), hstyle)
+		} else {
+			x = widget.SetCells(x, y, string(r), style)
+		}
+	}
 }
@@ -226,7 +226,9 @@ func (f *Filer) Input(key string) {
 	}
 
 	if ext, ok := f.extmap[key]; ok {
-		if callback, ok := ext[".dir"]; ok && (f.File().IsDir() || f.File().stat.IsDir()) {
+		if callback, ok := ext[".line"]; ok && f.File().Line() > 0 {
+			callback()
+		} else if callback, ok := ext[".dir"]; ok && (f.File().IsDir() || f.File().stat.IsDir()) {
 			callback()
 		} else if callback, ok := ext[".exec"]; ok && f.File().IsExec() {
 			callback()
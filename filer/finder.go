@@ -2,9 +2,11 @@ package filer
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/anmitsu/goful/look"
+	"github.com/anmitsu/goful/util"
 	"github.com/anmitsu/goful/widget"
 	"github.com/mattn/go-runewidth"
 )
@@ -13,13 +15,20 @@ import (
 type Finder struct {
 	*widget.TextBox
 	dir        *Directory
-	names      []string
+	files      []*FileStat
 	startname  string
 	historyPos int
+	fuzzy      bool         // matches fuzzily instead of regexp
+	ranked     []fuzzyMatch // files matching fuzzily in order of the rank
 }
 
 var finderHistory = make([]string, 0, 100)
 
+var fuzzyFinder = false
+
+// SetFuzzyFinder sets whether finders match files fuzzily instead of regexp.
+func SetFuzzyFinder(fuzzy bool) { fuzzyFinder = fuzzy }
+
 var finderKeymap func(*Finder) widget.Keymap
 
 // ConfigFinder sets the finder keymap function.
@@ -29,23 +38,24 @@ func ConfigFinder(config func(*Finder) widget.Keymap) {
 
 // NewFinder returns a new finder to position the directory bottom.
 func NewFinder(dir *Directory, x, y, width, height int) *Finder {
-	names := make([]string, len(dir.List()))
+	files := make([]*FileStat, len(dir.List()))
 	for i := 0; i < len(dir.List()); i++ {
-		names[i] = dir.List()[i].Name()
+		files[i] = dir.List()[i].(*FileStat)
 	}
 
 	finder := &Finder{
 		TextBox:    widget.NewTextBox(x, y, width, height),
 		dir:        dir,
-		names:      names,
+		files:      files,
 		startname:  dir.CurrentContent().Name(),
 		historyPos: 0,
+		fuzzy:      fuzzyFinder,
 	}
 
 	if len(finderHistory) < 1 {
 		finderHistory = append(finderHistory, "")
 	}
-	finder.Edithook = func() { dir.read() }
+	finder.Edithook = func() { dir.read(nil) }
 	return finder
 }
 
@@ -84,33 +94,175 @@ func (f *Finder) addHistory() {
 	}
 }
 
-func (f *Finder) find(callback func(name string)) {
+// ToggleFuzzy toggles fuzzy matching and regexp matching of the finder.
+func (f *Finder) ToggleFuzzy() {
+	f.fuzzy = !f.fuzzy
+	f.Edithook()
+}
+
+func (f *Finder) find() {
+	if f.fuzzy {
+		f.findFuzzy()
+		return
+	}
+	re := f.regexp()
+	if re == nil {
+		return
+	}
+
+	current := ""
+	if !f.dir.IsEmpty() {
+		current = f.dir.CurrentContent().Name()
+	}
+	f.dir.ClearList()
+	f.appendRegexp(re, f.files)
+	if f.dir.IsEmpty() {
+		f.dir.AppendList(NewFileStatFS(f.dir.fs, f.dir.Path, ".."))
+	}
+	if current != "" {
+		f.dir.SetCursorByName(current)
+		f.dir.SetOffsetCenteredCursor()
+	}
+}
+
+// regexp returns the regexp of the input ignoring case if lower case, or nil
+// if invalid.
+func (f *Finder) regexp() *regexp.Regexp {
 	expr := f.String()
 	if expr == strings.ToLower(expr) {
 		expr = "(?i)" + expr // case insensitive
 	}
 	re, err := regexp.Compile(expr)
 	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// appendRegexp appends the files matching the regexp to the list.
+func (f *Finder) appendRegexp(re *regexp.Regexp, files []*FileStat) {
+	for _, fs := range files {
+		fs.matches = nil
+		if re.MatchString(fs.findName()) {
+			fs.Markoff()
+			f.dir.AppendList(fs)
+		}
+	}
+}
+
+// appendFiles adds files read in the background filtering only them, not to
+// filter all files again for each batch.
+func (f *Finder) appendFiles(files []*FileStat) {
+	f.files = append(f.files, files...)
+	if f.fuzzy {
+		f.mergeFuzzy(files)
 		return
 	}
+	re := f.regexp()
+	if re == nil {
+		return
+	}
+	if f.isNoMatch() {
+		f.dir.ClearList()
+	}
+	f.appendRegexp(re, files)
+	if f.dir.IsEmpty() {
+		f.dir.AppendList(NewFileStatFS(f.dir.fs, f.dir.Path, ".."))
+	}
+}
 
+// isNoMatch reports whether the list has only ".." as no files matching.
+func (f *Finder) isNoMatch() bool {
+	return len(f.dir.List()) == 1 && f.dir.File().Name() == ".."
+}
+
+// fuzzyMatch is a file matching the input fuzzily ranked by the score.
+type fuzzyMatch struct {
+	fs    *FileStat
+	score int
+}
+
+// matchFuzzy returns the files matching the pattern fuzzily ranked in order of
+// the score and the name length keeping the order of the same ranks.
+func matchFuzzy(pattern string, files []*FileStat) []fuzzyMatch {
+	matches := []fuzzyMatch{}
+	for _, fs := range files {
+		fs.matches = nil
+		if score, pos, ok := util.FuzzyMatch(pattern, fs.findName()); ok {
+			fs.Markoff()
+			fs.matches = pos
+			matches = append(matches, fuzzyMatch{fs, score})
+		}
+	}
+	if pattern != "" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].less(matches[j]) })
+	}
+	return matches
+}
+
+func (m fuzzyMatch) less(n fuzzyMatch) bool {
+	if m.score != n.score {
+		return m.score > n.score
+	}
+	return len(m.fs.findName()) < len(n.fs.findName())
+}
+
+// findFuzzy lists files matching the input fuzzily in order of the score and
+// the name length, and sets the cursor to the best match.
+func (f *Finder) findFuzzy() {
+	pattern := f.String()
+	f.ranked = matchFuzzy(pattern, f.files)
 	current := ""
-	if !f.dir.IsEmpty() {
+	if pattern == "" && !f.dir.IsEmpty() {
 		current = f.dir.CurrentContent().Name()
 	}
-	f.dir.ClearList()
-	for _, name := range f.names {
-		if re.MatchString(name) {
-			callback(name)
+	f.listFuzzy(current)
+}
+
+// mergeFuzzy matches the files fuzzily and merges them into the ranked files
+// keeping the cursor file unless on the best match.
+func (f *Finder) mergeFuzzy(files []*FileStat) {
+	matches := matchFuzzy(f.String(), files)
+	if len(matches) < 1 && !f.dir.IsEmpty() {
+		return
+	}
+	merged := make([]fuzzyMatch, 0, len(f.ranked)+len(matches))
+	i, j := 0, 0
+	for i < len(f.ranked) && j < len(matches) {
+		if matches[j].less(f.ranked[i]) {
+			merged = append(merged, matches[j])
+			j++
+		} else {
+			merged = append(merged, f.ranked[i])
+			i++
 		}
 	}
+	merged = append(merged, f.ranked[i:]...)
+	f.ranked = append(merged, matches[j:]...)
+
+	current := ""
+	if f.dir.Cursor() > 0 {
+		current = f.dir.File().Name()
+	}
+	f.listFuzzy(current)
+}
+
+// listFuzzy lists the ranked files and sets the cursor to the named file or to
+// the best match.
+func (f *Finder) listFuzzy(current string) {
+	f.dir.ClearList()
+	for _, m := range f.ranked {
+		f.dir.AppendList(m.fs)
+	}
 	if f.dir.IsEmpty() {
-		f.dir.AppendList(NewFileStat(f.dir.Path, ".."))
+		f.dir.AppendList(NewFileStatFS(f.dir.fs, f.dir.Path, ".."))
 	}
 	if current != "" {
 		f.dir.SetCursorByName(current)
-		f.dir.SetOffsetCenteredCursor()
+	} else {
+		f.dir.SetCursor(0)
 	}
+	f.dir.SetOffsetCenteredCursor()
 }
 
 // Draw the finder and show a cursor if focus is true.
@@ -118,6 +270,11 @@ func (f *Finder) Draw(focus bool) {
 	f.Clear()
 	x, y := f.LeftTop()
 	s := "Find: " + f.String()
+	if f.IsRecursive() {
+		s = "Find file: " + f.String()
+	} else if f.fuzzy {
+		s = "Fuzzy: " + f.String()
+	}
 	x = widget.SetCells(x, y, s, look.Finder())
 	spacewidth := f.Width() - runewidth.StringWidth(s)
 	if spacewidth > 0 {
@@ -132,17 +289,21 @@ func (f *Finder) Draw(focus bool) {
 func (f *Finder) Exit() {
 	f.exitNotRead()
 	name := f.startname
-	if len(f.dir.List()) > 0 {
+	if _, ok := f.dir.reader.(fileIndex); ok {
+		f.dir.reader = defaultReader(".")
+	} else if len(f.dir.List()) > 0 {
 		name = f.dir.File().Name()
 	}
-	f.dir.read()
-	f.dir.SetCursorByName(name)
-	f.dir.SetOffsetCenteredCursor()
+	f.dir.read(func() {
+		f.dir.SetCursorByName(name)
+		f.dir.SetOffsetCenteredCursor()
+	})
 }
 
 func (f *Finder) exitNotRead() {
 	f.dir.ResizeRelative(0, 0, 0, 1)
-	f.names = nil
+	f.files = nil
+	f.ranked = nil
 	f.dir.finder = nil
 	f.addHistory()
 	widget.HideCursor()
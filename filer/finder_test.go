@@ -0,0 +1,120 @@
+package filer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/anmitsu/goful/vfs"
+)
+
+func TestFindFuzzy(t *testing.T) {
+	mem := vfs.NewMem()
+	for _, name := range []string{"/home/fabric.go", "/home/foo_bar.go", "/home/readme"} {
+		if err := mem.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetFuzzyFinder(true)
+	defer SetFuzzyFinder(false)
+	d := NewDirectory(0, 0, 80, 20)
+	d.Mount(mem, "/home")
+	d.SetCursorByName("readme")
+	d.Finder()
+
+	d.finder.SetText("fb")
+	d.finder.find()
+	names := []string{}
+	for _, e := range d.List() {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "foo_bar.go" || names[1] != "fabric.go" {
+		t.Errorf("listed %q", names)
+	}
+	if d.File().Name() != "foo_bar.go" || len(d.File().matches) != 2 {
+		t.Errorf("cursor %s matched %v", d.File().Name(), d.File().matches)
+	}
+
+	// files read later are merged into the ranking
+	mem.WriteFile("/home/fb.txt", nil, 0644)
+	mem.WriteFile("/home/xfxb", nil, 0644)
+	d.finder.appendFiles([]*FileStat{
+		NewFileStatFS(mem, "/home", "fb.txt"),
+		NewFileStatFS(mem, "/home", "xfxb"),
+	})
+	names = names[:0]
+	for _, e := range d.List() {
+		names = append(names, e.Name())
+	}
+	if len(names) != 4 || names[0] != "fb.txt" || names[1] != "foo_bar.go" || names[3] != "xfxb" {
+		t.Errorf("merged %q", names)
+	}
+	d.finder.find()
+	for i, e := range d.List() {
+		if e.Name() != names[i] {
+			t.Errorf("merged %q, but found %s at %d", names, e.Name(), i)
+		}
+	}
+
+	d.finder.SetText("")
+	d.finder.find()
+	if len(d.List()) != 5 || d.File().Name() != "fb.txt" || d.File().matches != nil {
+		t.Errorf("listed %d files at %s", len(d.List()), d.File().Name())
+	}
+}
+
+func TestFindFile(t *testing.T) {
+	mem := vfs.NewMem()
+	files := map[string]string{
+		"/home/.gitignore":          "*.o\nbuild/\n",
+		"/home/.git/config":         "",
+		"/home/main.go":             "",
+		"/home/main.o":              "",
+		"/home/build/out":           "",
+		"/home/src/.gitignore":      "gen_*\n",
+		"/home/src/app/handler.go":  "",
+		"/home/src/app/gen_help.go": "",
+	}
+	for name, data := range files {
+		if err := mem.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDirectory(0, 0, 80, 20)
+	d.Mount(mem, "/home")
+	d.SetCursorByName("main.go")
+	d.FindFile()
+	if !d.finder.IsRecursive() {
+		t.Fatal("not recursive")
+	}
+	names := map[string]bool{}
+	for _, e := range d.List() {
+		names[filepath.ToSlash(e.Name())] = true
+	}
+	want := []string{".gitignore", "main.go", "src/.gitignore", "src/app/handler.go"}
+	if len(names) != len(want) {
+		t.Errorf("indexed %v", names)
+	}
+	for _, name := range want {
+		if !names[name] {
+			t.Errorf("not indexed %s in %v", name, names)
+		}
+	}
+
+	d.finder.SetText("hand")
+	d.finder.find()
+	if len(d.List()) != 1 {
+		t.Fatalf("found %d files", len(d.List()))
+	}
+	d.finder.Jump()
+	if d.finder != nil || d.Path != filepath.FromSlash("/home/src/app") || d.File().Name() != "handler.go" {
+		t.Errorf("jumped to %s at %s", d.Path, d.File().Name())
+	}
+
+	d.Chdir("/home")
+	d.SetCursorByName("main.go")
+	d.FindFile()
+	d.finder.Exit()
+	if _, ok := d.reader.(defaultReader); !ok || d.File().Name() != "main.go" {
+		t.Errorf("exited to %s at %s", d.reader, d.File().Name())
+	}
+}
@@ -0,0 +1,115 @@
+package filer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/vfs"
+)
+
+// fileIndex lists files in the directory tree by relative paths for the
+// recursive finder.
+type fileIndex struct{}
+
+func (fileIndex) String() string { return "Files" }
+
+func (fileIndex) Read(fsys vfs.FS, dir string, callback func(*FileStat) bool) {
+	walkIndex(fsys, dir, func(path, rel string, info os.FileInfo) bool {
+		if info == nil {
+			return callback(nil)
+		}
+		return callback(newFileStatInfo(fsys, path, rel, info))
+	})
+}
+
+// walkIndex walks files in the directory tree calling fn with the path, the
+// relative path and the lstat until fn returns false.  Files ignored by
+// .gitignore, .git directories and hidden files unless shown are skipped.  fn
+// is also called with nil info entering each directory to report whether to
+// continue.
+func walkIndex(fsys vfs.FS, dir string, fn func(path, rel string, info os.FileInfo) bool) {
+	ignore := &util.Gitignore{}
+	_ = vfs.Walk(fsys, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if path != dir {
+			name := info.Name()
+			if name == ".git" || !showHiddens && strings.HasPrefix(name, ".") || ignore.Match(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info.IsDir() {
+			if !fn(path, rel, nil) {
+				return io.EOF // stop walking
+			}
+			if data, err := readGitignore(fsys, filepath.Join(path, ".gitignore")); err == nil {
+				if rel == "." {
+					rel = ""
+				}
+				ignore.Add(rel, data)
+			}
+			return nil
+		}
+		if !fn(path, filepath.FromSlash(rel), info) {
+			return io.EOF
+		}
+		return nil
+	})
+}
+
+func readGitignore(fsys vfs.FS, path string) ([]byte, error) {
+	r, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// FindFile starts the recursive finder filtering files in the directory tree
+// fuzzily while indexing them in the background.  Jump changes to the directory
+// of the found file and exiting returns to the directory.
+func (d *Directory) FindFile() {
+	if d.finder != nil {
+		d.finder.exitNotRead()
+	}
+	name := d.File().Name()
+	d.setReader(fileIndex{})
+	d.read(func() { d.SetCursor(0) })
+	d.Finder()
+	d.finder.startname = name
+	d.finder.fuzzy = true
+}
+
+// IsRecursive reports whether the finder filters files in the directory tree.
+func (f *Finder) IsRecursive() bool {
+	_, ok := f.dir.reader.(fileIndex)
+	return ok
+}
+
+// Jump exits the recursive finder and changes to the directory of the file on
+// the cursor setting the cursor to the file.
+func (f *Finder) Jump() {
+	d := f.dir
+	if !f.IsRecursive() || d.File().Name() == ".." {
+		return
+	}
+	dir, name := filepath.Split(d.File().Path())
+	dir = filepath.Clean(dir)
+	f.exitNotRead()
+	d.history[dir] = name
+	d.Chdir(dir)
+}
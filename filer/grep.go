@@ -0,0 +1,201 @@
+package filer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/vfs"
+)
+
+const (
+	grepBinaryProbe = 8000        // bytes to detect binary files containing NUL
+	grepLineMax     = 1024 * 1024 // longer lines stop scanning the file
+	grepLineView    = 512         // runes of the line shown in the list
+	grepPoll        = 100 * time.Millisecond
+)
+
+// grepPattern lists lines matching the regexp in files of the directory tree
+// displayed as "path:line:text" and named by the file name.  Files are skipped
+// like the recursive finder, and binary files are also skipped.  Lower case
+// patterns ignore case.
+type grepPattern string
+
+func (p grepPattern) String() string {
+	return fmt.Sprintf("Grep:(%s)", string(p))
+}
+
+func (p grepPattern) Read(fsys vfs.FS, dir string, callback func(*FileStat) bool) {
+	expr := string(p)
+	if expr == strings.ToLower(expr) {
+		expr = "(?i)" + expr // case insensitive
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		message.Error(err)
+		return
+	}
+
+	type file struct {
+		path, rel string
+		info      os.FileInfo
+	}
+	files := make(chan file)
+	results := make(chan []*FileStat)
+	done := make(chan struct{})
+	go func() {
+		walkIndex(fsys, dir, func(path, rel string, info os.FileInfo) bool {
+			if info != nil && !info.Mode().IsRegular() {
+				return true
+			}
+			select {
+			case <-done:
+				return false
+			default:
+			}
+			if info != nil {
+				select {
+				case files <- file{path, rel, info}:
+				case <-done:
+					return false
+				}
+			}
+			return true
+		})
+		close(files)
+	}()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				matches := grepFile(fsys, f.path, f.rel, f.info, re, done)
+				if len(matches) < 1 {
+					continue
+				}
+				select {
+				case results <- matches:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	tick := time.NewTicker(grepPoll)
+	defer tick.Stop()
+	stop := func() {
+		close(done)
+		go func() {
+			for range results {
+			}
+		}()
+	}
+	for {
+		select {
+		case matches, ok := <-results:
+			if !ok {
+				return
+			}
+			for _, fs := range matches {
+				if !callback(fs) {
+					stop()
+					return
+				}
+			}
+		case <-tick.C:
+			if !callback(nil) {
+				stop()
+				return
+			}
+		}
+	}
+}
+
+// grepFile returns file stats of lines matching the regexp in the file unless
+// binary.
+func grepFile(fsys vfs.FS, path, rel string, info os.FileInfo, re *regexp.Regexp, done <-chan struct{}) []*FileStat {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if head, _ := r.Peek(grepBinaryProbe); bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), grepLineMax)
+	matches := []*FileStat{}
+	for n := 1; scanner.Scan(); n++ {
+		if n%1000 == 0 {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+		}
+		line := scanner.Bytes()
+		if !re.Match(line) {
+			continue
+		}
+		text := grepLineText(line)
+		prefix := fmt.Sprintf("%s:%d:", rel, n)
+		fs := newFileStat(path, info.Name(), info, info)
+		fs.SetDisplay(prefix + text)
+		fs.line = n
+		offset := utf8.RuneCountInString(prefix)
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			start := offset + utf8.RuneCountInString(text[:loc[0]])
+			end := offset + utf8.RuneCountInString(text[:loc[1]])
+			for i := start; i < end; i++ {
+				fs.matches = append(fs.matches, i)
+			}
+		}
+		matches = append(matches, fs)
+	}
+	return matches
+}
+
+// grepLineText returns the line text to show replacing tabs and control
+// characters by spaces and truncating too long lines.
+func grepLineText(line []byte) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range string(line) {
+		if n == grepLineView {
+			break
+		}
+		if r < ' ' || r == utf8.RuneError || r == 0x7f {
+			r = ' '
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
+
+// Grep sets a reader to list lines matching the regexp in files of the
+// directory tree.  Lines are searched concurrently in the background.
+func (d *Directory) Grep(pattern string) {
+	d.setReader(grepPattern(pattern))
+	d.read(func() { d.SetCursor(0) })
+}
+
+// Line returns the line number of the file listed by grep or 0.
+func (f *FileStat) Line() int {
+	return f.line
+}
@@ -0,0 +1,59 @@
+package filer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/anmitsu/goful/vfs"
+)
+
+func TestGrep(t *testing.T) {
+	mem := vfs.NewMem()
+	files := map[string]string{
+		"/home/.gitignore":  "*.log\n",
+		"/home/a.go":        "package a\n\nfunc Foo() {}\n",
+		"/home/sub/b.txt":   "foo\nbar\n\tfoo foo\n",
+		"/home/sub/bin.dat": "foo\x00",
+		"/home/x.log":       "foo\n",
+	}
+	for name, data := range files {
+		if err := mem.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDirectory(0, 0, 80, 20)
+	d.Mount(mem, "/home")
+	d.Grep("foo")
+	names, displays := []string{}, []string{}
+	for _, e := range d.List() {
+		names = append(names, e.Name())
+		displays = append(displays, filepath.ToSlash(e.(*FileStat).display))
+	}
+	want := []string{"a.go:3:func Foo() {}", "sub/b.txt:1:foo", "sub/b.txt:3: foo foo"}
+	if !reflect.DeepEqual(displays, want) {
+		t.Fatalf("grep listed %q, want %q", displays, want)
+	}
+	if want := []string{"a.go", "b.txt", "b.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("grep named %q, want %q", names, want)
+	}
+	fs := d.List()[2].(*FileStat)
+	if fs.Line() != 3 || fs.Ext() != ".txt" || fs.Path() != filepath.FromSlash("/home/sub/b.txt") {
+		t.Errorf("line %d ext %s path %s", fs.Line(), fs.Ext(), fs.Path())
+	}
+	if want := []int{13, 14, 15, 17, 18, 19}; !reflect.DeepEqual(fs.matches, want) {
+		t.Errorf("highlighted %v, want %v", fs.matches, want)
+	}
+
+	d.InvertMark()
+	paths := []string{filepath.FromSlash("/home/a.go"), filepath.FromSlash("/home/sub/b.txt")}
+	if marked := d.MarkfilePaths(); !reflect.DeepEqual(marked, paths) {
+		t.Errorf("marked %q, want %q", marked, paths)
+	}
+	d.MarkClear()
+
+	d.Reset()
+	if len(d.List()) != 4 {
+		t.Errorf("reset to %d files", len(d.List()))
+	}
+}
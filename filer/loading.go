@@ -0,0 +1,158 @@
+package filer
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var syncCallback func(func())
+
+// SetSyncCallback sets the function to run callbacks in the main goroutine.
+// Directories are read in the background streaming files if set.
+func SetSyncCallback(f func(func())) { syncCallback = f }
+
+const (
+	loadingBatch    = 1000                   // files sent to the list at once
+	loadingInterval = 100 * time.Millisecond // or sent at this interval
+)
+
+// loading is the state of reading a directory in the background.
+type loading struct {
+	count       int64 // read files updated atomically
+	canceled    int32 // set atomically to stop reading
+	placeholder bool  // the list has only ".." until files are read
+}
+
+func (l *loading) loaded() int64 { return atomic.LoadInt64(&l.count) }
+
+func (l *loading) cancel() { atomic.StoreInt32(&l.canceled, 1) }
+
+func (l *loading) isCanceled() bool { return atomic.LoadInt32(&l.canceled) != 0 }
+
+// cancelLoading stops reading in the background.  Files already sent are
+// discarded.
+func (d *Directory) cancelLoading() {
+	if d.loading != nil {
+		d.loading.cancel()
+		d.loading = nil
+	}
+}
+
+// listKey identifies the listing of the directory by the reader to reload in
+// place.
+func (d *Directory) listKey() string {
+	return fmt.Sprintf("%s\x00%s\x00%s", d.Path, d.Title(), d.reader.String())
+}
+
+// load reads files in the background and calls finish with the cursor file
+// name in the main goroutine after reading.  Files stream into the list when
+// listing another directory, or replace the list at once when reloading not to
+// flash the list.
+func (d *Directory) load(finish func(cursor string)) {
+	l := &loading{}
+	d.loading = l
+	key := d.listKey()
+	stream := d.listed != key
+	if stream {
+		d.listed = ""
+		d.ClearList()
+		d.AppendList(NewFileStatFS(d.fs, d.Path, ".."))
+		d.SetCursor(0)
+		l.placeholder = true
+	}
+	reader, fsys, path, post := d.reader, d.fs, d.Path, syncCallback
+	go func() {
+		files := []*FileStat{}
+		sent := 0
+		last := time.Now()
+		reader.Read(fsys, path, func(fs *FileStat) bool {
+			if l.isCanceled() {
+				return false
+			} else if fs == nil {
+				return true
+			}
+			files = append(files, fs)
+			atomic.AddInt64(&l.count, 1)
+			if len(files)-sent < loadingBatch && time.Since(last) < loadingInterval {
+				return true
+			}
+			batch := files[sent:]
+			sent, last = len(files), time.Now()
+			post(func() {
+				// only redraws the count if not streaming
+				if d.loading == l && stream {
+					d.appendLoaded(l, batch)
+				}
+			})
+			return true
+		})
+		rest := files[sent:]
+		post(func() {
+			if d.loading != l {
+				return
+			}
+			d.loading = nil
+			cursor := ""
+			d.AdjustCursor()
+			if !d.IsEmpty() {
+				cursor = d.File().Name()
+			}
+			if stream {
+				d.appendLoaded(l, rest)
+			} else {
+				d.setLoaded(files)
+			}
+			d.listed = key
+			finish(cursor)
+		})
+	}()
+}
+
+// appendLoaded appends files read in the background to the list or to the
+// finder filtering the list.
+func (d *Directory) appendLoaded(l *loading, files []*FileStat) {
+	if len(files) < 1 {
+		return
+	}
+	if l.placeholder {
+		l.placeholder = false
+		d.ClearList()
+		if d.finder != nil {
+			d.finder.files = nil
+			d.finder.ranked = nil
+		}
+	}
+	if d.finder != nil {
+		d.finder.appendFiles(files)
+		return
+	}
+	for _, fs := range files {
+		d.AppendList(fs)
+	}
+}
+
+// setLoaded replaces the list with files read in the background keeping marks
+// set while reading.
+func (d *Directory) setLoaded(files []*FileStat) {
+	marked := map[string]bool{}
+	for _, e := range d.List() {
+		if e.(*FileStat).IsMarked() {
+			marked[e.(*FileStat).Path()] = true
+		}
+	}
+	for _, fs := range files {
+		if marked[fs.Path()] {
+			fs.Mark()
+		}
+	}
+	if d.finder != nil {
+		d.finder.files = files
+		d.finder.find()
+		return
+	}
+	d.ClearList()
+	for _, fs := range files {
+		d.AppendList(fs)
+	}
+}
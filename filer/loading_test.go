@@ -0,0 +1,105 @@
+package filer
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	big, small := t.TempDir(), t.TempDir()
+	for i := 0; i < 2500; i++ {
+		if err := ioutil.WriteFile(filepath.Join(big, fmt.Sprintf("f%04d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(small, "only"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	callbacks := make(chan func())
+	SetSyncCallback(func(f func()) { callbacks <- f })
+	defer SetSyncCallback(nil)
+	wait := func(d *Directory) {
+		for d.loading != nil {
+			(<-callbacks)()
+		}
+	}
+
+	d := NewDirectory(0, 0, 80, 20)
+	d.Chdir(big)
+	if d.loading == nil || d.File().Name() != ".." {
+		t.Fatalf("read synchronously: %d files", len(d.List()))
+	}
+	wait(d)
+	if len(d.List()) != 2500 || d.File().Name() != "f0000" || d.List()[2499].Name() != "f2499" {
+		t.Fatalf("loaded %d files, cursor %s", len(d.List()), d.File().Name())
+	}
+
+	// reloading keeps the list until reading ends
+	d.SetCursorByName("f1234")
+	d.File().Mark()
+	d.reload(nil)
+	if len(d.List()) != 2500 {
+		t.Errorf("list cleared while reloading: %d files", len(d.List()))
+	}
+	wait(d)
+	if d.File().Name() != "f1234" || !d.File().IsMarked() {
+		t.Errorf("reloaded cursor %s, marked %v", d.File().Name(), d.File().IsMarked())
+	}
+
+	// moving away cancels reading
+	d.Chdir(big)
+	d.Chdir(small)
+	wait(d)
+	if len(d.List()) != 1 || d.File().Name() != "only" {
+		t.Errorf("listed %d files after canceled, cursor %s", len(d.List()), d.File().Name())
+	}
+
+	// archives are listed in the background
+	arc := filepath.Join(small, "test.zip")
+	if err := writeZip(arc, "dir/a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	d.Chdir(small)
+	wait(d)
+	d.SetCursorByName("test.zip")
+	d.EnterArchive()
+	if d.loading == nil {
+		t.Fatalf("listed the archive synchronously")
+	}
+	wait(d)
+	if len(d.List()) != 2 || d.File().Name() != "dir" {
+		t.Errorf("listed %d entries in the archive", len(d.List()))
+	}
+	d.Chdir("..")
+	wait(d)
+
+	// filtering keeps reading
+	d.Chdir(big)
+	d.Finder()
+	d.finder.SetText("f1")
+	d.finder.Edithook()
+	wait(d)
+	if len(d.List()) != 1000 {
+		t.Errorf("filtered %d files while reading", len(d.List()))
+	}
+}
+
+func writeZip(file string, names ...string) error {
+	fd, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	zw := zip.NewWriter(fd)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
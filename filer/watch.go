@@ -0,0 +1,156 @@
+package filer
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anmitsu/goful/message"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher watches directories for changes by inotify on Linux and the native
+// notification elsewhere.  Changed directories are reported in batches at most
+// once per the interval to reload bursts of events at once.
+type Watcher struct {
+	watcher  *fsnotify.Watcher
+	interval time.Duration
+	changed  chan map[string]bool
+
+	mu      sync.Mutex
+	watched map[string]bool
+}
+
+// NewWatcher creates a new watcher reporting changes at most once per the
+// interval.
+func NewWatcher(interval time.Duration) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		watcher:  fw,
+		interval: interval,
+		changed:  make(chan map[string]bool),
+		watched:  map[string]bool{},
+	}
+	go w.run()
+	return w, nil
+}
+
+// Changed returns the channel receiving sets of changed directory paths.  A nil
+// watcher returns nil channel blocking forever.
+func (w *Watcher) Changed() <-chan map[string]bool {
+	if w == nil {
+		return nil
+	}
+	return w.changed
+}
+
+// Sync watches the paths and removes watches of other paths.
+func (w *Watcher) Sync(paths []string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keep := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		keep[path] = true
+		if w.watched[path] {
+			continue
+		}
+		// mark as watched even if failed not to retry every drawing
+		w.watched[path] = true
+		if err := w.watcher.Add(path); err != nil {
+			message.Error(err)
+		}
+	}
+	for path := range w.watched {
+		if !keep[path] {
+			w.watcher.Remove(path)
+			delete(w.watched, path)
+		}
+	}
+}
+
+// Close the watcher.
+func (w *Watcher) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.watcher.Close()
+}
+
+func (w *Watcher) isWatched(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.watched[path]
+}
+
+// run collects events and sends changed directories after the interval from
+// the first event.
+func (w *Watcher) run() {
+	pending := map[string]bool{}
+	var timer <-chan time.Time
+	var out chan map[string]bool
+	for {
+		select {
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// the event of an entry in the directory or the directory itself
+			for _, path := range []string{filepath.Dir(ev.Name), ev.Name} {
+				if w.isWatched(path) {
+					pending[path] = true
+				}
+			}
+			if len(pending) > 0 && timer == nil && out == nil {
+				timer = time.After(w.interval)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			message.Error(err)
+		case <-timer:
+			timer = nil
+			out = w.changed
+		case out <- pending:
+			out = nil
+			pending = map[string]bool{}
+		}
+	}
+}
+
+// VisiblePaths returns paths of directories on the local disk in the current
+// workspace.
+func (f *Filer) VisiblePaths() []string {
+	paths := []string{}
+	for _, d := range f.Workspace().Dirs {
+		if d.IsLocal() {
+			paths = append(paths, d.Path)
+		}
+	}
+	return paths
+}
+
+// ReloadPaths reloads directories of the paths in place keeping the cursor
+// file name and marks.  Directories listed by other readers such as grep and
+// glob are not reloaded not to walk the trees again for every change.
+func (w *Workspace) ReloadPaths(paths map[string]bool) {
+	reloaded := false
+	for _, d := range w.Dirs {
+		if _, ok := d.reader.(defaultReader); !ok {
+			continue
+		}
+		if d.IsLocal() && paths[d.Path] {
+			d.reload(nil)
+			reloaded = true
+		}
+	}
+	if reloaded {
+		w.attach()
+	}
+}
@@ -0,0 +1,73 @@
+package filer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(100 * time.Millisecond)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer w.Close()
+	w.Sync([]string{dir})
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case paths := <-w.Changed():
+		if len(paths) != 1 || !paths[dir] {
+			t.Errorf("changed %v, want %s", paths, dir)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no changes reported")
+	}
+	// the burst of events is reported at once
+	select {
+	case paths := <-w.Changed():
+		t.Errorf("changed again %v", paths)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	w.Sync(nil)
+	if err := ioutil.WriteFile(filepath.Join(dir, "d"), []byte("d"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case paths := <-w.Changed():
+		t.Errorf("changed after unwatched %v", paths)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestReloadPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorkspace(0, 0, 80, 20, "test")
+	for i := 0; i < 2; i++ {
+		d := NewDirectory(0, 0, 80, 20)
+		d.Chdir(dir)
+		w.Dirs = append(w.Dirs, d)
+	}
+	w.Dirs[1].Glob("*.txt")
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.ReloadPaths(map[string]bool{dir: true})
+	if n := len(w.Dirs[0].List()); n != 2 {
+		t.Errorf("reloaded %d files, want 2", n)
+	}
+	if n := len(w.Dirs[1].List()); n != 1 {
+		t.Errorf("reloaded the glob listing to %d files, want 1", n)
+	}
+}
@@ -73,6 +73,10 @@ func (w *Workspace) CloseDir() {
 
 // ChdirNeighbor changes the focused path a neighbor directory path.
 func (w *Workspace) ChdirNeighbor() {
+	if next := w.NextDir(); next.fs != w.Dir().fs {
+		w.Dir().Mount(next.fs, next.Path)
+		return
+	}
 	w.Dir().Chdir(w.NextDir().Path)
 }
 
@@ -109,24 +113,16 @@ func (w *Workspace) SetFocus(x int) {
 }
 
 func (w *Workspace) attach() {
-	err := os.Chdir(w.Dir().Path)
-	if err != nil {
+	if err := w.Dir().enter(w.Dir().Path); err != nil {
 		message.Error(err)
-		home, _ := os.UserHomeDir()
-		w.Dir().Chdir(home)
+		w.Dir().fallback()
 	}
 }
 
 // ReloadAll reloads all directories.
 func (w *Workspace) ReloadAll() {
 	for _, d := range w.Dirs {
-		d.reload()
-	}
-	err := os.Chdir(w.Dir().Path)
-	if err != nil {
-		message.Error(err)
-		home, _ := os.UserHomeDir()
-		w.Dir().Chdir(home)
+		d.reload(nil)
 	}
 }
 
@@ -3,11 +3,14 @@ module github.com/anmitsu/goful
 go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/gdamore/tcell/v2 v2.4.0
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
+	github.com/kevinburke/ssh_config v1.1.0
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.13
-	golang.org/x/sys v0.0.0-20211124211545-fe61309f8881 // indirect
-	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211 // indirect
-	golang.org/x/text v0.3.7 // indirect
+	github.com/pkg/sftp v1.13.6
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/crypto v0.1.0
+	golang.org/x/sys v0.1.0
 )
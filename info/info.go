@@ -2,16 +2,32 @@
 package info
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/anmitsu/goful/util"
 	"github.com/anmitsu/goful/widget"
 )
 
 var info *infoWindow
 
-// Draw the information bar for file information.
-func Draw(fi os.FileInfo) {
-	info.draw(fi)
+// Draw the information bar for file information and the disk space of the
+// directory.  The directory is "" if not on the local disk, and the disk space
+// is shown as "-".
+func Draw(dir string, fi os.FileInfo) {
+	info.draw(dir, fi)
+}
+
+// space returns the free size and the used percentage of the disk containing
+// the directory.
+func space(dir string) string {
+	if dir != "" {
+		if free, all, err := diskSpace(dir); err == nil && all > 0 {
+			used := float64(all-free) / float64(all) * 100
+			return fmt.Sprintf("%s free %.1f%% used", util.FormatSize(int64(free)), used)
+		}
+	}
+	return "- free - used"
 }
 
 // Resize the information bar.
@@ -31,6 +47,11 @@ func Init() {
 	info = &infoWindow{widget.NewWindow(0, height-1, width, 1)}
 }
 
+// owner is a file info source having the owner names, such as an archive entry.
+type owner interface {
+	Owner() (user, group string)
+}
+
 // infoWindow is the information window to display file information.
 type infoWindow struct {
 	*widget.Window
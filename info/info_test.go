@@ -0,0 +1,15 @@
+package info
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpace(t *testing.T) {
+	if s := space(""); s != "- free - used" {
+		t.Errorf("space off the local disk %q", s)
+	}
+	if s := space(t.TempDir()); !strings.HasSuffix(s, "% used") || strings.HasPrefix(s, "-") {
+		t.Errorf("space of the directory %q", s)
+	}
+}
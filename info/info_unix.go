@@ -9,33 +9,36 @@ import (
 	"syscall"
 
 	"github.com/anmitsu/goful/look"
-	"github.com/anmitsu/goful/util"
 	"github.com/anmitsu/goful/widget"
 	"github.com/mattn/go-runewidth"
 )
 
-func (w *infoWindow) draw(fi os.FileInfo) {
+func diskSpace(dir string) (free, all uint64, err error) {
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &statfs); err != nil {
+		return 0, 0, err
+	}
+	return statfs.Bavail * uint64(statfs.Bsize), statfs.Blocks * uint64(statfs.Bsize), nil
+}
+
+func (w *infoWindow) draw(dir string, fi os.FileInfo) {
 	w.Clear()
 	x, y := w.LeftTop()
 
-	var statfs syscall.Statfs_t
-	_ = syscall.Statfs(".", &statfs)
-	free := statfs.Bavail * uint64(statfs.Bsize)
-	all := statfs.Blocks * uint64(statfs.Bsize)
-	used := float64(all-free) / float64(all) * 100
-	freeSI := util.FormatSize(int64(free))
-
-	stat := fi.Sys().(*syscall.Stat_t)
-	var username, group string
-	if u, err := user.LookupId(fmt.Sprintf("%d", stat.Uid)); err != nil {
-		username = "unknown"
-	} else {
-		username = u.Name
-	}
-	if u, err := user.LookupGroupId(fmt.Sprintf("%d", stat.Gid)); err != nil {
-		group = "unknown"
-	} else {
-		group = u.Name
+	username, group := "unknown", "unknown"
+	var nlink uint64 = 1
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if u, err := user.LookupId(fmt.Sprintf("%d", stat.Uid)); err == nil {
+			username = u.Name
+		}
+		if u, err := user.LookupGroupId(fmt.Sprintf("%d", stat.Gid)); err == nil {
+			group = u.Name
+		}
+		nlink = uint64(stat.Nlink)
+	} else if o, ok := fi.Sys().(owner); ok { // an archive entry
+		if u, g := o.Owner(); u != "" {
+			username, group = u, g
+		}
 	}
 
 	perm := fi.Mode().String()
@@ -43,8 +46,8 @@ func (w *infoWindow) draw(fi os.FileInfo) {
 	mtime := fi.ModTime().String()
 	name := fi.Name()
 
-	info := fmt.Sprintf("%s free %.1f%% used %s %s %s %d %d %s %s",
-		freeSI, used, perm, username, group, stat.Nlink, size, mtime, name)
+	info := fmt.Sprintf("%s %s %s %s %d %d %s %s",
+		space(dir), perm, username, group, nlink, size, mtime, name)
 	s := runewidth.Truncate(info, w.Width(), "~")
 	widget.SetCells(x, y, s, look.Default())
 }
@@ -9,12 +9,28 @@ import (
 	"unsafe"
 
 	"github.com/anmitsu/goful/look"
-	"github.com/anmitsu/goful/util"
 	"github.com/anmitsu/goful/widget"
 	"github.com/mattn/go-runewidth"
 )
 
-func (w *infoWindow) draw(fi os.FileInfo) {
+func diskSpace(dir string) (free, all uint64, err error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	h := syscall.MustLoadDLL("kernel32.dll")
+	c := h.MustFindProc("GetDiskFreeSpaceExW")
+	r, _, err := c.Call(uintptr(unsafe.Pointer(path)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&all)),
+		uintptr(unsafe.Pointer(nil)))
+	if r == 0 {
+		return 0, 0, err
+	}
+	return free, all, nil
+}
+
+func (w *infoWindow) draw(dir string, fi os.FileInfo) {
 	w.Clear()
 	x, y := w.LeftTop()
 
@@ -23,18 +39,7 @@ func (w *infoWindow) draw(fi os.FileInfo) {
 	mtime := fi.ModTime().String()
 	name := fi.Name()
 
-	h := syscall.MustLoadDLL("kernel32.dll")
-	c := h.MustFindProc("GetDiskFreeSpaceExW")
-	var free, all int64
-	_, _, _ = c.Call(uintptr(
-		unsafe.Pointer(syscall.StringToUTF16Ptr("."))),
-		uintptr(unsafe.Pointer(&free)),
-		uintptr(unsafe.Pointer(&all)),
-		uintptr(unsafe.Pointer(nil)))
-	used := float64(all-free) / float64(all) * 100
-	freeSI := util.FormatSize(free)
-
-	info := fmt.Sprintf("%s free %.1f%% used %s %d %s %s", freeSI, used, perm, size, mtime, name)
+	info := fmt.Sprintf("%s %s %d %s %s", space(dir), perm, size, mtime, name)
 	s := runewidth.Truncate(info, w.Width(), "~")
 	widget.SetCells(x, y, s, look.Default())
 }
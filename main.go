@@ -11,6 +11,7 @@ import (
 	"github.com/anmitsu/goful/look"
 	"github.com/anmitsu/goful/menu"
 	"github.com/anmitsu/goful/message"
+	"github.com/anmitsu/goful/preview"
 	"github.com/anmitsu/goful/widget"
 	"github.com/mattn/go-runewidth"
 )
@@ -34,10 +35,12 @@ func main() {
 
 	const state = "~/.goful/state.json"
 	const history = "~/.goful/history/shell"
+	const journal = "~/.goful/journal.json"
 
 	goful := app.NewGoful(state)
 	config(goful, is_tmux)
 	_ = cmdline.LoadHistory(history)
+	_ = goful.SetJournal(journal)
 
 	goful.Run()
 
@@ -62,15 +65,26 @@ func config(g *app.Goful, is_tmux bool) {
 	message.SetErrorLog("~/.goful/log/error.log") // "" is not logging
 	message.Sec(5)                                // display second for a message
 
+	g.SetJobWorkers(2)                // number of copy, move and remove jobs running concurrently
+	g.SetCopyVerify("")               // "sha256", "md5", "crc32" (faster) or "" is not verifying copied files
+	g.SetCopyArchive(false)           // preserve ownership as root, xattrs and hard links like `cp -a`
+	g.SetCopyBuffer(128 * 1024)       // buffer size for copying without reflinks and copy_file_range
+	g.SetResumeDir("~/.goful/resume") // "" is not resuming copies after quitting or crashing
+
 	// Setup widget keymaps.
 	g.ConfigFiler(filerKeymap)
 	filer.ConfigFinder(finderKeymap)
 	cmdline.Config(cmdlineKeymap)
 	cmdline.ConfigCompletion(completionKeymap)
 	menu.Config(menuKeymap)
+	app.ConfigJobList(jobListKeymap)
+	app.ConfigSyncList(syncListKeymap)
+	app.ConfigDiskUsage(diskUsageKeymap)
+	preview.Config(previewKeymap)
 
 	filer.SetStatView(true, false, true)  // size, permission and time
 	filer.SetTimeFormat("06-01-02 15:04") // ex: "Jan _2 15:04"
+	filer.SetFuzzyFinder(false)           // true is fuzzy matching instead of regexp in the finder
 
 	// Setup open command for C-m (when the enter key is pressed)
 	// The macro %f means expanded to a file name, for more see (spawn.go)
@@ -102,6 +116,21 @@ func config(g *app.Goful, is_tmux bool) {
 	}
 	g.AddKeymap("i", func() { g.Spawn(pager) })
 
+	// Setup an editor by $EDITOR to open grep matches at the line by the macro %l
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+	if runtime.GOOS == "windows" {
+		editor += " %~F"
+	} else {
+		editor += " +%l %F"
+	}
+
 	// Setup a shell and a terminal to execute external commands.
 	// The shell is called when execute on background by the macro %&.
 	// The terminal is called when the other.
@@ -121,7 +150,7 @@ func config(g *app.Goful, is_tmux bool) {
 			const tail = `;read -p "HIT ENTER KEY"`
 
 			if is_tmux { // such as screen and tmux
-				return []string{"tmux", "new-window", "-n", cmd, cmd + tail}
+				return []string{"tmux", "new-window", "-c", g.Dir().Path, "-n", cmd, cmd + tail}
 			}
 			// To execute bash in gnome-terminal of a new window or tab.
 			title := "echo -n '\033]0;" + cmd + "\007';" // for change title
@@ -147,6 +176,8 @@ func config(g *app.Goful, is_tmux bool) {
 		"s", "stat menu    ", func() { g.Menu("stat") },
 		"l", "layout menu  ", func() { g.Menu("layout") },
 		"L", "look menu    ", func() { g.Menu("look") },
+		"p", "preview pane ", func() { g.TogglePreview() },
+		"V", "view file    ", func() { g.View() },
 		".", "toggle show hidden files", func() { filer.ToggleShowHiddens(); g.Workspace().ReloadAll() },
 	)
 	g.AddKeymap("v", func() { g.Menu("view") })
@@ -164,6 +195,7 @@ func config(g *app.Goful, is_tmux bool) {
 		"s", "toggle size  ", func() { filer.ToggleSizeView() },
 		"p", "toggle perm  ", func() { filer.TogglePermView() },
 		"t", "toggle time  ", func() { filer.ToggleTimeView() },
+		"d", "toggle dir size", func() { filer.ToggleDirSizeView(); g.Workspace().ReloadAll() },
 		"1", "all stat     ", func() { filer.SetStatView(true, true, true) },
 		"0", "no stat      ", func() { filer.SetStatView(false, false, false) },
 	)
@@ -181,7 +213,8 @@ func config(g *app.Goful, is_tmux bool) {
 	menu.Add("command",
 		"c", "copy         ", func() { g.Copy() },
 		"m", "move         ", func() { g.Move() },
-		"D", "delete       ", func() { g.Remove() },
+		"D", "trash        ", func() { g.Remove() },
+		"!", "delete       ", func() { g.RemovePermanently() },
 		"k", "mkdir        ", func() { g.Mkdir() },
 		"n", "newfile      ", func() { g.Touch() },
 		"M", "chmod        ", func() { g.Chmod() },
@@ -190,6 +223,19 @@ func config(g *app.Goful, is_tmux bool) {
 		"d", "chdir        ", func() { g.Chdir() },
 		"g", "glob         ", func() { g.Glob() },
 		"G", "globdir      ", func() { g.Globdir() },
+		"f", "find file    ", func() { g.Dir().FindFile() },
+		"/", "grep         ", func() { g.Grep() },
+		"C", "cancel job   ", func() { g.CancelJob() },
+		"P", "pause job    ", func() { g.PauseJob() },
+		"J", "job list     ", func() { g.JobList() },
+		"u", "undo         ", func() { g.Undo() },
+		"U", "redo         ", func() { g.Redo() },
+		"S", "sync panes   ", func() { g.Sync() },
+		"a", "archive      ", func() { g.Archive(".zip") },
+		"e", "extract      ", func() { g.Extract() },
+		"=", "compare      ", func() { g.Compare(false) },
+		"+", "compare hash ", func() { g.Compare(true) },
+		"s", "disk usage   ", func() { g.DiskUsage() },
 	)
 	g.AddKeymap("x", func() { g.Menu("command") })
 
@@ -222,18 +268,20 @@ func config(g *app.Goful, is_tmux bool) {
 	g.AddKeymap("X", func() { g.Menu("external-command") })
 
 	menu.Add("archive",
-		"z", "zip     ", func() { g.Shell(`zip -roD %x.zip %m`, -7) },
-		"t", "tar     ", func() { g.Shell(`tar cvf %x.tar %m`, -7) },
-		"g", "tar.gz  ", func() { g.Shell(`tar cvfz %x.tgz %m`, -7) },
+		"z", "zip     ", func() { g.Archive(".zip") },
+		"t", "tar     ", func() { g.Archive(".tar") },
+		"g", "tar.gz  ", func() { g.Archive(".tar.gz") },
+		"x", "tar.xz  ", func() { g.Archive(".tar.xz") },
+
+		"e", "extract for %m", func() { g.Extract() },
+
+		"X", "external archivers menu", func() { g.Menu("external-archive") },
+	)
+
+	menu.Add("external-archive",
 		"b", "tar.bz2 ", func() { g.Shell(`tar cvfj %x.bz2 %m`, -7) },
-		"x", "tar.xz  ", func() { g.Shell(`tar cvfJ %x.txz %m`, -7) },
 		"r", "rar     ", func() { g.Shell(`rar u %x.rar %m`, -7) },
 
-		"Z", "extract zip for %m", func() { g.Shell(`for i in %m; do unzip "$i" -d ./; done`, -6) },
-		"T", "extract tar for %m", func() { g.Shell(`for i in %m; do tar xvf "$i" -C ./; done`, -6) },
-		"G", "extract tgz for %m", func() { g.Shell(`for i in %m; do tar xvfz "$i" -C ./; done`, -6) },
-		"B", "extract bz2 for %m", func() { g.Shell(`for i in %m; do tar xvfj "$i" -C ./; done`, -6) },
-		"X", "extract txz for %m", func() { g.Shell(`for i in %m; do tar xvfJ "$i" -C ./; done`, -6) },
 		"R", "extract rar for %m", func() { g.Shell(`for i in %m; do unrar x "$i" -C ./; done`, -6) },
 
 		"1", "find . *.zip extract", func() { g.Shell(`find . -name "*.zip" -type f -prune -print0 | xargs -n1 -0 unzip -d ./`) },
@@ -244,6 +292,13 @@ func config(g *app.Goful, is_tmux bool) {
 		"6", "find . *.rar extract", func() { g.Shell(`find . -name "*.rar" -type f -prune -print0 | xargs -n1 -0 unrar x -C ./`) },
 	)
 
+	menu.Add("trash",
+		"t", "list trash    ", func() { g.Dir().Trash() },
+		"r", "restore       ", func() { g.RestoreTrash() },
+		"e", "empty trash   ", func() { g.EmptyTrash() },
+	)
+	g.AddKeymap("T", func() { g.Menu("trash") })
+
 	menu.Add("bookmark",
 		"t", "~/Desktop  ", func() { g.Dir().Chdir("~/Desktop") },
 		"c", "~/Documents", func() { g.Dir().Chdir("~/Documents") },
@@ -268,9 +323,9 @@ func config(g *app.Goful, is_tmux bool) {
 	g.AddKeymap("b", func() { g.Menu("bookmark") })
 
 	menu.Add("editor",
-		"c", "vscode        ", func() { g.Spawn("code %f %&") },
-		"e", "emacs client  ", func() { g.Spawn("emacsclient -n %f %&") },
-		"v", "vim           ", func() { g.Spawn("vim %f") },
+		"c", "vscode        ", func() { g.Spawn("code -g %F:%l %&") },
+		"e", "emacs client  ", func() { g.Spawn("emacsclient -n +%l %F %&") },
+		"v", "vim           ", func() { g.Spawn("vim +%l %F") },
 	)
 	g.AddKeymap("e", func() { g.Menu("editor") })
 
@@ -289,24 +344,27 @@ func config(g *app.Goful, is_tmux bool) {
 	var associate widget.Keymap
 	if runtime.GOOS == "windows" {
 		associate = widget.Keymap{
-			".dir": func() { g.Dir().EnterDir() },
-			".go":  func() { g.Shell("go run %~f") },
-			".py":  func() { g.Shell("python %~f") },
-			".rb":  func() { g.Shell("ruby %~f") },
-			".js":  func() { g.Shell("node %~f") },
+			".dir":  func() { g.Dir().EnterDir() },
+			".line": func() { g.Spawn(editor) },
+			".zip":  func() { g.Dir().EnterArchive() },
+			".go":   func() { g.Shell("go run %~f") },
+			".py":   func() { g.Shell("python %~f") },
+			".rb":   func() { g.Shell("ruby %~f") },
+			".js":   func() { g.Shell("node %~f") },
 		}
 	} else {
 		associate = widget.Keymap{
 			".dir":  func() { g.Dir().EnterDir() },
 			".exec": func() { g.Shell(" ./" + g.File().Name()) },
-
-			".zip": func() { g.Shell("unzip %f -d %D") },
-			".tar": func() { g.Shell("tar xvf %f -C %D") },
-			".gz":  func() { g.Shell("tar xvfz %f -C %D") },
-			".tgz": func() { g.Shell("tar xvfz %f -C %D") },
-			".bz2": func() { g.Shell("tar xvfj %f -C %D") },
-			".xz":  func() { g.Shell("tar xvfJ %f -C %D") },
-			".txz": func() { g.Shell("tar xvfJ %f -C %D") },
+			".line": func() { g.Spawn(editor) },
+
+			".zip": func() { g.Dir().EnterArchive() },
+			".tar": func() { g.Dir().EnterArchive() },
+			".gz":  func() { g.Dir().EnterArchive() },
+			".tgz": func() { g.Dir().EnterArchive() },
+			".bz2": func() { g.Dir().EnterArchive() },
+			".xz":  func() { g.Dir().EnterArchive() },
+			".txz": func() { g.Dir().EnterArchive() },
 			".rar": func() { g.Shell("unrar x %f -C %D") },
 
 			".go": func() { g.Shell("go run %f") },
@@ -347,7 +405,7 @@ func filerKeymap(g *app.Goful) widget.Keymap {
 		"M-b":       func() { g.MoveWorkspace(-1) },
 		"C-o":       func() { g.Workspace().CreateDir() },
 		"C-w":       func() { g.Workspace().CloseDir() },
-		"C-l":       func() { g.Workspace().ReloadAll() },
+		"C-l":       func() { filer.ClearDirSizes(); g.Workspace().ReloadAll() },
 		"C-f":       func() { g.Workspace().MoveFocus(1) },
 		"C-b":       func() { g.Workspace().MoveFocus(-1) },
 		"right":     func() { g.Workspace().MoveFocus(1) },
@@ -389,6 +447,8 @@ func filerKeymap(g *app.Goful) widget.Keymap {
 		"C-[":       func() { g.Dir().Reset() }, // C-[ means ESC
 		"f":         func() { g.Dir().Finder() },
 		"/":         func() { g.Dir().Finder() },
+		"M-/":       func() { g.Dir().FindFile() },
+		"M-g":       func() { g.Grep() },
 		"q":         func() { g.Quit() },
 		"Q":         func() { g.Quit() },
 		";":         func() { g.Shell("") },
@@ -401,21 +461,38 @@ func filerKeymap(g *app.Goful) widget.Keymap {
 		"r":         func() { g.Rename() },
 		"R":         func() { g.BulkRename() },
 		"D":         func() { g.Remove() },
+		"M-D":       func() { g.RemovePermanently() },
 		"d":         func() { g.Chdir() },
 		"g":         func() { g.Glob() },
 		"G":         func() { g.Globdir() },
+		"C-c":       func() { g.CancelJob() },
+		"P":         func() { g.PauseJob() },
+		"J":         func() { g.JobList() },
+		"U":         func() { g.Undo() },
+		"C-r":       func() { g.Redo() },
+		"S":         func() { g.Sync() },
+		"=":         func() { g.Compare(false) },
+		"M-+":       func() { g.Compare(true) },
+		"p":         func() { g.TogglePreview() },
+		"V":         func() { g.View() },
+		"M-u":       func() { g.DiskUsage() },
 	}
 }
 
 func finderKeymap(w *filer.Finder) widget.Keymap {
-	return widget.Keymap{
+	keymap := widget.Keymap{
 		"C-h":       func() { w.DeleteBackwardChar() },
 		"backspace": func() { w.DeleteBackwardChar() },
 		"M-p":       func() { w.MoveHistory(1) },
 		"M-n":       func() { w.MoveHistory(-1) },
+		"C-t":       func() { w.ToggleFuzzy() },
 		"C-g":       func() { w.Exit() },
 		"C-[":       func() { w.Exit() },
 	}
+	if w.IsRecursive() {
+		keymap["C-m"] = func() { w.Jump() }
+	}
+	return keymap
 }
 
 func cmdlineKeymap(w *cmdline.Cmdline) widget.Keymap {
@@ -484,6 +561,106 @@ func completionKeymap(w *cmdline.Completion) widget.Keymap {
 	}
 }
 
+func jobListKeymap(w *app.JobList) widget.Keymap {
+	return widget.Keymap{
+		"C-n":  func() { w.MoveCursor(1) },
+		"C-p":  func() { w.MoveCursor(-1) },
+		"down": func() { w.MoveCursor(1) },
+		"up":   func() { w.MoveCursor(-1) },
+		"j":    func() { w.MoveCursor(1) },
+		"k":    func() { w.MoveCursor(-1) },
+		"C-v":  func() { w.PageDown() },
+		"M-v":  func() { w.PageUp() },
+		"M->":  func() { w.MoveBottom() },
+		"M-<":  func() { w.MoveTop() },
+		"c":    func() { w.Cancel() },
+		"p":    func() { w.TogglePause() },
+		"r":    func() { w.Retry() },
+		"x":    func() { w.ClearFinished() },
+		"C-g":  func() { w.Exit() },
+		"C-[":  func() { w.Exit() },
+		"q":    func() { w.Exit() },
+	}
+}
+
+func syncListKeymap(w *app.SyncList) widget.Keymap {
+	return widget.Keymap{
+		"C-n":  func() { w.MoveCursor(1) },
+		"C-p":  func() { w.MoveCursor(-1) },
+		"down": func() { w.MoveCursor(1) },
+		"up":   func() { w.MoveCursor(-1) },
+		"j":    func() { w.MoveCursor(1) },
+		"k":    func() { w.MoveCursor(-1) },
+		"C-v":  func() { w.PageDown() },
+		"M-v":  func() { w.PageUp() },
+		"M->":  func() { w.MoveBottom() },
+		"M-<":  func() { w.MoveTop() },
+		"u":    func() { w.Update() },
+		"m":    func() { w.Mirror() },
+		"t":    func() { w.TwoWay() },
+		"C-g":  func() { w.Exit() },
+		"C-[":  func() { w.Exit() },
+		"q":    func() { w.Exit() },
+	}
+}
+
+func diskUsageKeymap(w *app.DiskUsage) widget.Keymap {
+	return widget.Keymap{
+		"C-n":       func() { w.MoveCursor(1) },
+		"C-p":       func() { w.MoveCursor(-1) },
+		"down":      func() { w.MoveCursor(1) },
+		"up":        func() { w.MoveCursor(-1) },
+		"j":         func() { w.MoveCursor(1) },
+		"k":         func() { w.MoveCursor(-1) },
+		"C-v":       func() { w.PageDown() },
+		"M-v":       func() { w.PageUp() },
+		"M->":       func() { w.MoveBottom() },
+		"M-<":       func() { w.MoveTop() },
+		"C-m":       func() { w.Enter() },
+		"l":         func() { w.Enter() },
+		"right":     func() { w.Enter() },
+		"u":         func() { w.Up() },
+		"h":         func() { w.Up() },
+		"left":      func() { w.Up() },
+		"C-h":       func() { w.Up() },
+		"backspace": func() { w.Up() },
+		"r":         func() { w.Rescan() },
+		"d":         func() { w.Chdir() },
+		"D":         func() { w.Trash() },
+		"M-D":       func() { w.Remove() },
+		"C-g":       func() { w.Exit() },
+		"C-[":       func() { w.Exit() },
+		"q":         func() { w.Exit() },
+	}
+}
+
+func previewKeymap(w *preview.Preview) widget.Keymap {
+	return widget.Keymap{
+		"C-n":  func() { w.Scroll(1) },
+		"C-p":  func() { w.Scroll(-1) },
+		"down": func() { w.Scroll(1) },
+		"up":   func() { w.Scroll(-1) },
+		"j":    func() { w.Scroll(1) },
+		"k":    func() { w.Scroll(-1) },
+		"C-v":  func() { w.PageDown() },
+		"M-v":  func() { w.PageUp() },
+		"pgdn": func() { w.PageDown() },
+		"pgup": func() { w.PageUp() },
+		" ":    func() { w.PageDown() },
+		"M->":  func() { w.MoveBottom() },
+		"M-<":  func() { w.MoveTop() },
+		"G":    func() { w.MoveBottom() },
+		"g":    func() { w.MoveTop() },
+		"/":    func() { w.StartSearch() },
+		"n":    func() { w.SearchNext() },
+		"N":    func() { w.SearchPrev() },
+		"x":    func() { w.ToggleHex() },
+		"C-g":  func() { w.Exit() },
+		"C-[":  func() { w.Exit() },
+		"q":    func() { w.Exit() },
+	}
+}
+
 func menuKeymap(w *menu.Menu) widget.Keymap {
 	return widget.Keymap{
 		"C-n":  func() { w.MoveCursor(1) },
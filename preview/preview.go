@@ -0,0 +1,665 @@
+// Package preview provides the file preview widget displaying contents as text
+// or a hex dump.  Files are read lazily around the displayed offset, so large
+// files open instantly.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/anmitsu/goful/filer"
+	"github.com/anmitsu/goful/look"
+	"github.com/anmitsu/goful/util"
+	"github.com/anmitsu/goful/widget"
+	"github.com/mattn/go-runewidth"
+)
+
+var keymap func(*Preview) widget.Keymap
+
+// Config the keymap function for a preview.
+func Config(config func(*Preview) widget.Keymap) {
+	keymap = config
+}
+
+const (
+	chunkSize  = 64 * 1024 // bytes read at once
+	sniffSize  = 8000      // bytes to detect binary files
+	hexColumns = 16        // bytes per hex dump row
+	tabStop    = 8
+)
+
+var redraw = func() {}
+
+// SetRedraw sets the function to redraw called from background goroutines
+// after directory summaries are calculated.
+func SetRedraw(f func()) { redraw = f }
+
+// Preview is a window to display the file contents.
+type Preview struct {
+	*widget.Window
+	filer  widget.Widget
+	file   *os.File
+	path   string
+	names  []string // directory entries if the path is a directory
+	more   bool     // directory entries are more than names
+	err    error
+	size   int64
+	hex    bool    // dump as hex
+	top    int64   // byte offset of the top row
+	rows   []int64 // byte offsets of displayed rows
+	query  string  // search query
+	search *widget.TextBox
+
+	cancel    context.CancelFunc // cancels calculating the directory summary
+	summaryMu sync.Mutex
+	summary   string
+}
+
+// New creates a new preview.  The filer is disconnected to exit the preview
+// if not nil.
+func New(x, y, width, height int, filer widget.Widget) *Preview {
+	return &Preview{
+		Window: widget.NewWindow(x, y, width, height),
+		filer:  filer,
+	}
+}
+
+// Path returns the previewing file path.
+func (p *Preview) Path() string { return p.path }
+
+// Open the file to preview.  Binary files are displayed as hex dumps.
+func (p *Preview) Open(path string) {
+	if path == p.path {
+		return
+	}
+	p.Close()
+	p.path = path
+	p.top = 0
+	p.hex = false
+	p.names = nil
+	p.more = false
+	p.err = nil
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		p.err = err
+		return
+	}
+	if stat.IsDir() {
+		p.names, p.more, p.err = readNames(path, 1000)
+		p.summarize(path, stat)
+		return
+	}
+	if !stat.Mode().IsRegular() {
+		p.err = fmt.Errorf("%s: not a regular file", stat.Mode().Type())
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.file = file
+	p.size = stat.Size()
+	p.hex = isBinary(p.readAt(0, sniffSize))
+}
+
+// summarize calculates the total size and the file count of the directory in
+// the background until the preview opens another path.
+func (p *Preview) summarize(path string, stat os.FileInfo) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.setSummary("calculating...")
+	go func() {
+		size, count, err := filer.CalcDirSize(ctx, path, stat.ModTime())
+		if err != nil {
+			return
+		}
+		if p.setSummaryContext(ctx, fmt.Sprintf("%d files %s", count, util.FormatSize(size))) {
+			redraw()
+		}
+	}()
+}
+
+func (p *Preview) setSummary(s string) {
+	p.summaryMu.Lock()
+	p.summary = s
+	p.summaryMu.Unlock()
+}
+
+// setSummaryContext sets the summary unless the context is canceled by
+// opening another path, and reports whether set.
+func (p *Preview) setSummaryContext(ctx context.Context, s string) bool {
+	p.summaryMu.Lock()
+	defer p.summaryMu.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	p.summary = s
+	return true
+}
+
+func (p *Preview) getSummary() string {
+	p.summaryMu.Lock()
+	defer p.summaryMu.Unlock()
+	return p.summary
+}
+
+// Close the previewing file.
+func (p *Preview) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+	p.path = ""
+}
+
+// readNames returns sorted names of at most n entries in the directory with
+// "/" after directory names, and whether more entries exist.
+func readNames(path string, n int) ([]string, bool, error) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer dir.Close()
+	fis, err := dir.Readdir(n + 1)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	names := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() {
+			names = append(names, fi.Name()+"/")
+		} else {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	if len(names) > n {
+		return names[:n], true, nil
+	}
+	return names, false, nil
+}
+
+// isBinary reports whether the data contains NUL or invalid UTF-8 bytes.
+func isBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	// ignore a rune cut at the end
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return !utf8.Valid(data)
+}
+
+func (p *Preview) readAt(off int64, n int) []byte {
+	if p.file == nil || off < 0 {
+		return nil
+	}
+	buf := make([]byte, n)
+	m, err := p.file.ReadAt(buf, off)
+	if err != nil && err != io.EOF {
+		p.err = err
+	}
+	return buf[:m]
+}
+
+// textWidth returns the width of contents in the window.
+func (p *Preview) textWidth() int {
+	if w := p.Width() - 2; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// textHeight returns the number of rows in the window.
+func (p *Preview) textHeight() int {
+	if h := p.Height() - 2; h > 0 {
+		return h
+	}
+	return 1
+}
+
+// row is a displayed row of the data from start to end bytes.
+type row struct {
+	start, end int
+	text       string
+}
+
+// wrap splits the data into rows by newlines and the width.  Tabs are
+// expanded and control characters are displayed as "?".  The last row not
+// ending with a newline is included only if eof is true.
+func wrap(data []byte, width int, eof bool, max int) []row {
+	rows := []row{}
+	var b strings.Builder
+	start, col := 0, 0
+	for i := 0; i < len(data) && len(rows) < max; {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == '\n' {
+			rows = append(rows, row{start, i + size, strings.TrimSuffix(b.String(), "\r")})
+			b.Reset()
+			i += size
+			start, col = i, 0
+			continue
+		}
+		if r == utf8.RuneError && size == 1 && !eof && len(data)-i < utf8.UTFMax {
+			break // a rune cut at the end of the chunk
+		}
+		var s string
+		switch {
+		case r == '\t':
+			s = strings.Repeat(" ", tabStop-col%tabStop)
+		case r == '\r':
+			s = "\r"
+		case r < ' ' || r == 0x7f || r == utf8.RuneError:
+			s = "?"
+		default:
+			s = string(r)
+		}
+		w := runewidth.StringWidth(s)
+		if s == "\r" {
+			w = 0
+		}
+		if col+w > width && col > 0 {
+			rows = append(rows, row{start, i, b.String()})
+			b.Reset()
+			start, col = i, 0
+			if r == '\t' {
+				s = strings.Repeat(" ", tabStop)
+				w = tabStop
+			}
+		}
+		b.WriteString(s)
+		col += w
+		i += size
+	}
+	if eof && start < len(data) && len(rows) < max {
+		rows = append(rows, row{start, len(data), b.String()})
+	}
+	return rows
+}
+
+// textRows returns at most n rows from the offset.
+func (p *Preview) textRows(off int64, n int) []row {
+	data := p.readAt(off, chunkSize)
+	eof := off+int64(len(data)) >= p.size
+	rows := wrap(data, p.textWidth(), eof, n)
+	if len(rows) == 0 && len(data) > 0 {
+		// a line longer than the chunk without the end of runes
+		rows = append(rows, row{0, len(data), string(data)})
+	}
+	return rows
+}
+
+// prevRow returns the offset of the row before the row at the offset.
+func (p *Preview) prevRow(off int64) int64 {
+	if off <= 0 {
+		return 0
+	}
+	if p.hex {
+		return max64(0, (off-1)/hexColumns*hexColumns)
+	}
+	start := max64(0, off-chunkSize)
+	data := p.readAt(start, int(off-start))
+	lineStart := start
+	if len(data) > 0 {
+		if i := bytes.LastIndexByte(data[:len(data)-1], '\n'); i >= 0 {
+			lineStart = start + int64(i) + 1
+		}
+	}
+	rows := wrap(data[lineStart-start:], p.textWidth(), true, chunkSize)
+	if len(rows) == 0 {
+		return lineStart
+	}
+	return lineStart + int64(rows[len(rows)-1].start)
+}
+
+// rowOf returns the offset of the row containing the offset.
+func (p *Preview) rowOf(off int64) int64 {
+	if p.hex {
+		return off / hexColumns * hexColumns
+	}
+	return p.prevRow(off + 1)
+}
+
+// Scroll the preview by rows.
+func (p *Preview) Scroll(amount int) {
+	for ; amount < 0 && p.top > 0; amount++ {
+		p.top = p.prevRow(p.top)
+	}
+	if amount <= 0 {
+		return
+	}
+	if p.hex {
+		last := max64(0, (p.size-1)/hexColumns*hexColumns)
+		p.top = min64(last, p.top+int64(amount)*hexColumns)
+		return
+	}
+	for amount > 0 {
+		rows := p.textRows(p.top, amount+1)
+		if len(rows) < 2 {
+			return
+		}
+		n := minInt(amount, len(rows)-1)
+		p.top += int64(rows[n].start)
+		amount -= n
+	}
+}
+
+// PageDown scrolls down by the window height.
+func (p *Preview) PageDown() { p.Scroll(p.textHeight()) }
+
+// PageUp scrolls up by the window height.
+func (p *Preview) PageUp() { p.Scroll(-p.textHeight()) }
+
+// MoveTop moves to the beginning of the file.
+func (p *Preview) MoveTop() { p.top = 0 }
+
+// MoveBottom moves to display the end of the file.
+func (p *Preview) MoveBottom() {
+	p.top = p.prevRow(p.size)
+	p.Scroll(-(p.textHeight() - 1))
+}
+
+// ToggleHex toggles the hex dump and the text view.
+func (p *Preview) ToggleHex() {
+	p.hex = !p.hex
+	p.top = p.rowOf(p.top)
+}
+
+// StartSearch starts the search box to input a query.
+func (p *Preview) StartSearch() {
+	x, y := p.LeftBottom()
+	p.search = widget.NewTextBox(x, y, p.Width(), 1)
+}
+
+// SearchNext moves to the next match of the query.  Queries of lower case
+// letters are case insensitive.
+func (p *Preview) SearchNext() { p.find(true) }
+
+// SearchPrev moves to the previous match of the query.
+func (p *Preview) SearchPrev() { p.find(false) }
+
+func (p *Preview) find(forward bool) {
+	if p.query == "" || p.file == nil {
+		return
+	}
+	var off int64
+	var ok bool
+	if forward {
+		from := p.top + 1
+		if len(p.rows) > 1 {
+			from = p.rows[1]
+		}
+		off, ok = p.index(from)
+	} else {
+		off, ok = p.lastIndex(p.top)
+	}
+	if !ok {
+		p.err = fmt.Errorf("not found %q", p.query)
+		return
+	}
+	p.err = nil
+	p.top = p.rowOf(off)
+}
+
+func (p *Preview) pattern() []byte {
+	return []byte(p.query)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// fold lowers ASCII letters of the data if the query is lower case.  Other
+// letters are kept not to shift byte offsets of matches.
+func (p *Preview) fold(data []byte) []byte {
+	if p.query != strings.ToLower(p.query) {
+		return data
+	}
+	folded := make([]byte, len(data))
+	for i, c := range data {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		folded[i] = c
+	}
+	return folded
+}
+
+// index returns the offset of the first match from the offset.
+func (p *Preview) index(from int64) (int64, bool) {
+	q := p.pattern()
+	for off := from; off < p.size; off += int64(chunkSize - len(q) + 1) {
+		data := p.fold(p.readAt(off, chunkSize))
+		if i := bytes.Index(data, q); i >= 0 {
+			return off + int64(i), true
+		}
+		if len(data) < chunkSize {
+			break
+		}
+	}
+	return 0, false
+}
+
+// lastIndex returns the offset of the last match before the offset.
+func (p *Preview) lastIndex(before int64) (int64, bool) {
+	q := p.pattern()
+	for end := before + int64(len(q)) - 1; end > 0; end -= int64(chunkSize - len(q) + 1) {
+		start := max64(0, end-chunkSize)
+		data := p.fold(p.readAt(start, int(end-start)))
+		if i := bytes.LastIndex(data, q); i >= 0 && start+int64(i) < before {
+			return start + int64(i), true
+		}
+		if start == 0 {
+			break
+		}
+	}
+	return 0, false
+}
+
+// Input to the search box or the preview keymap.
+func (p *Preview) Input(key string) {
+	if p.search != nil {
+		p.inputSearch(key)
+		return
+	}
+	if callback, ok := keymap(p)[key]; ok {
+		callback()
+	}
+}
+
+func (p *Preview) inputSearch(key string) {
+	switch key {
+	case "C-m":
+		p.query = p.search.String()
+		p.search = nil
+		p.SearchNext()
+	case "C-g", "C-[":
+		p.search = nil
+	case "C-h", "backspace":
+		p.search.DeleteBackwardChar()
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			r, _ := utf8.DecodeRuneInString(key)
+			p.search.InsertChar(r)
+		}
+	}
+}
+
+// Exit the preview.
+func (p *Preview) Exit() {
+	p.Close()
+	if p.filer != nil {
+		p.filer.Disconnect()
+	}
+}
+
+// Next implements widget.Widget.
+func (p *Preview) Next() widget.Widget { return widget.Nil() }
+
+// Disconnect implements widget.Widget.
+func (p *Preview) Disconnect() {}
+
+// Draw the file contents in the window.
+func (p *Preview) Draw() {
+	p.Clear()
+	p.Border()
+	x, y := p.LeftTop()
+	title := util.AbbrPath(p.path)
+	widget.SetCells(x+1, y, runewidth.Truncate(title, p.Width()-2, "~"), look.Default())
+
+	if p.file != nil {
+		if stat, err := p.file.Stat(); err == nil {
+			p.size = stat.Size() // for growing files
+		}
+	}
+	switch {
+	case p.names != nil:
+		p.drawNames()
+	case p.file == nil:
+	case p.hex:
+		p.drawHex()
+	default:
+		p.drawText()
+	}
+	p.drawFooter()
+}
+
+func (p *Preview) drawNames() {
+	x, y := p.LeftTop()
+	for i, name := range p.names {
+		if i >= p.textHeight() {
+			break
+		}
+		widget.SetCells(x+1, y+1+i, runewidth.Truncate(name, p.textWidth(), "~"), look.Default())
+	}
+}
+
+func (p *Preview) drawText() {
+	x, y := p.LeftTop()
+	rows := p.textRows(p.top, p.textHeight())
+	p.rows = make([]int64, len(rows))
+	for i, r := range rows {
+		p.rows[i] = p.top + int64(r.start)
+		p.drawRow(x+1, y+1+i, r.text)
+	}
+}
+
+func (p *Preview) drawHex() {
+	x, y := p.LeftTop()
+	data := p.readAt(p.top, p.textHeight()*hexColumns)
+	p.rows = []int64{}
+	for i := 0; i*hexColumns < len(data); i++ {
+		line := data[i*hexColumns : minInt(len(data), (i+1)*hexColumns)]
+		p.rows = append(p.rows, p.top+int64(i*hexColumns))
+		p.drawRow(x+1, y+1+i, hexRow(p.top+int64(i*hexColumns), line))
+	}
+}
+
+// hexRow returns the row of the offset, hex bytes and printable characters.
+func hexRow(off int64, data []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x ", off)
+	for i := 0; i < hexColumns; i++ {
+		if i%8 == 0 {
+			b.WriteByte(' ')
+		}
+		if i < len(data) {
+			fmt.Fprintf(&b, "%02x ", data[i])
+		} else {
+			b.WriteString("   ")
+		}
+	}
+	b.WriteString(" |")
+	for _, c := range data {
+		if c < ' ' || c > '~' {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('|')
+	return b.String()
+}
+
+// drawRow draws the row text highlighting matches of the query.
+func (p *Preview) drawRow(x, y int, text string) {
+	text = runewidth.Truncate(text, p.textWidth(), "")
+	if p.query == "" {
+		widget.SetCells(x, y, text, look.Default())
+		return
+	}
+	haystack, query := string(p.fold([]byte(text))), p.query
+	for len(text) > 0 {
+		i := strings.Index(haystack, query)
+		if i < 0 {
+			widget.SetCells(x, y, text, look.Default())
+			return
+		}
+		x = widget.SetCells(x, y, text[:i], look.Default())
+		x = widget.SetCells(x, y, text[i:i+len(query)], look.Default().Reverse(true))
+		text, haystack = text[i+len(query):], haystack[i+len(query):]
+	}
+}
+
+func (p *Preview) drawFooter() {
+	x, y := p.LeftBottom()
+	if p.search != nil {
+		s := "Search: " + p.search.String()
+		x = widget.SetCells(x, y, runewidth.Truncate(s, p.Width(), "~"), look.Finder())
+		widget.ShowCursor(x, y)
+		return
+	}
+	var s string
+	switch {
+	case p.err != nil:
+		widget.SetCells(x, y, runewidth.Truncate(p.err.Error(), p.Width(), "~"), look.MessageError())
+		return
+	case p.names != nil:
+		more := ""
+		if p.more {
+			more = "+"
+		}
+		s = fmt.Sprintf("[Dir] %d%s entries, %s", len(p.names), more, p.getSummary())
+	case p.file != nil:
+		view := "Text"
+		if p.hex {
+			view = "Hex"
+		}
+		percent := 100
+		if p.size > 0 {
+			percent = int(p.top * 100 / p.size)
+		}
+		s = fmt.Sprintf("[%s] %d%% %s/%s", view, percent, util.FormatSize(p.top), util.FormatSize(p.size))
+	}
+	widget.SetCells(x, y, runewidth.Truncate(s, p.Width(), "~"), look.Default())
+}
@@ -0,0 +1,114 @@
+package preview
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	for _, c := range []struct {
+		data  string
+		width int
+		eof   bool
+		texts []string
+	}{
+		{"abc\ndef", 10, true, []string{"abc", "def"}},
+		{"abc\ndef", 10, false, []string{"abc"}},
+		{"abcdef\n", 4, true, []string{"abcd", "ef"}},
+		{"a\tb\r\n", 10, true, []string{"a       b"}},
+		{"あいう\n", 5, true, []string{"あい", "う"}},
+		{"a\x01b\n", 10, true, []string{"a?b"}},
+	} {
+		rows := wrap([]byte(c.data), c.width, c.eof, 100)
+		texts := []string{}
+		for _, r := range rows {
+			texts = append(texts, r.text)
+		}
+		if strings.Join(texts, "|") != strings.Join(c.texts, "|") {
+			t.Errorf("wrap(%q, %d)=%q, want %q", c.data, c.width, texts, c.texts)
+		}
+	}
+}
+
+func TestScrollSearch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "text")
+	lines := []string{}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, strings.Repeat("x", i%15))
+	}
+	lines[70] = "needle"
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := New(0, 0, 12, 12, nil)
+	p.Open(path)
+	defer p.Close()
+	if p.hex {
+		t.Fatal("text file opened as hex")
+	}
+	// rows of 10 columns wrap lines longer than 10
+	p.Scroll(12)
+	down := p.top
+	p.Scroll(-12)
+	if p.top != 0 {
+		t.Errorf("scrolled back to %d, want 0 (down to %d)", p.top, down)
+	}
+	p.query = "needle"
+	p.SearchNext()
+	if want := int64(strings.Index(strings.Join(lines, "\n"), "needle")); p.top != want {
+		t.Errorf("found at %d, want %d", p.top, want)
+	}
+	p.MoveBottom()
+	p.SearchPrev()
+	if !strings.HasPrefix(string(p.readAt(p.top, 6)), "needle") {
+		t.Errorf("found backward at %d", p.top)
+	}
+
+	bin := filepath.Join(dir, "bin")
+	if err := ioutil.WriteFile(bin, []byte{0, 1, 2, 0xff}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.Open(bin)
+	if !p.hex {
+		t.Error("binary file opened as text")
+	}
+	if s := hexRow(0, []byte("AB\x00")); !strings.HasPrefix(s, "00000000  41 42 00 ") || !strings.HasSuffix(s, "|AB.|") {
+		t.Errorf("hexRow=%q", s)
+	}
+}
+
+func TestSearchFold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text")
+	text := "İİİ ȺȺ Needle"
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := New(0, 0, 40, 12, nil)
+	p.Open(path)
+	defer p.Close()
+	p.query = "needle"
+	off, ok := p.index(0)
+	if want := int64(strings.Index(text, "Needle")); !ok || off != want {
+		t.Errorf("found at %d, want %d", off, want)
+	}
+	off, ok = p.lastIndex(p.size)
+	if want := int64(strings.Index(text, "Needle")); !ok || off != want {
+		t.Errorf("found backward at %d, want %d", off, want)
+	}
+}
+
+func TestSummaryAfterClose(t *testing.T) {
+	p := New(0, 0, 40, 12, nil)
+	p.Open(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Close()
+	p.setSummary("next")
+	if p.setSummaryContext(ctx, "stale") || p.getSummary() != "next" {
+		t.Errorf("summary of the closed directory set to %q", p.getSummary())
+	}
+}
@@ -4,6 +4,7 @@ package progress
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/anmitsu/goful/look"
 	"github.com/anmitsu/goful/util"
@@ -11,91 +12,133 @@ import (
 	"github.com/mattn/go-runewidth"
 )
 
-var progress *progressWindow
+var (
+	area       *widget.Window
+	progresses []*Progress
+	mutex      sync.Mutex
+)
 
-// Draw the progress task and gauge.
+// Draw all progress tasks and gauges stacked to the bottom of the area.
 func Draw() {
-	if !progress.gauge.IsFinished() {
-		progress.drawTask()
-		progress.gauge.Draw()
+	mutex.Lock()
+	defer mutex.Unlock()
+	x, y := area.LeftBottom()
+	y -= len(progresses)*2 - 1
+	for _, p := range progresses {
+		p.Resize(x, y, area.Width(), 1)
+		p.gauge.Resize(x, y+1, area.Width(), 1)
+		p.draw()
+		y += 2
 	}
 }
 
-// Start progressing to an arrival value.
-func Start(maxval float64) {
-	progress.gauge.Start(maxval)
-}
-
-// Finish progressing and the clear display.
-func Finish() {
-	progress.gauge.Finish()
-	progress.Clear()
-	progress.gauge.Clear()
-}
-
-// IsFinished reports whether progressing finished.
+// IsFinished reports whether all progresses finished.
 func IsFinished() bool {
-	return progress.gauge.IsFinished()
+	return Count() == 0
 }
 
-// Update progressing by a value.
-func Update(value float64) {
-	progress.gauge.Update(value)
+// Count returns the number of progressing.
+func Count() int {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return len(progresses)
 }
 
-// Resize the progress window and gauge.
+// Resize the area to draw progresses.  The bottom of the area is the position
+// of the last progress gauge.
 func Resize(x, y, width, height int) {
-	progress.Resize(x, y, width, height)
-	progress.gauge.Resize(x, y+1, width, height)
+	area.Resize(x, y, width, height)
 }
 
-// Init initializes the progress window and gauge at the bottom position.
+// Init initializes the progress area at the bottom position.
 func Init() {
 	width, height := widget.Size()
-	progress = &progressWindow{
-		widget.NewWindow(0, height-4, width, 1),
-		widget.NewProgressGauge(0, height-3, width, 1),
-		nil,
-		0,
-		0,
+	area = widget.NewWindow(0, height-4, width, 2)
+	progresses = []*Progress{}
+}
+
+// Progress is a progress window to display a file control task and a gauge.
+type Progress struct {
+	*widget.Window
+	gauge     *widget.ProgressGauge
+	mutex     sync.Mutex
+	task      os.FileInfo
+	taskCount int
+	done      int
+}
+
+// Start progressing to an arrival value with the task count.
+func Start(maxval float64, count int) *Progress {
+	p := &Progress{
+		Window:    widget.NewWindow(0, 0, 0, 1),
+		gauge:     widget.NewProgressGauge(0, 0, 0, 1),
+		task:      nil,
+		taskCount: count,
+		done:      0,
 	}
+	p.gauge.Start(maxval)
+	mutex.Lock()
+	progresses = append(progresses, p)
+	mutex.Unlock()
+	return p
 }
 
-// StartTask starts the file control task.
-func StartTask(fi os.FileInfo) {
-	progress.task = fi
+// Finish progressing and clear the display.
+func (p *Progress) Finish() {
+	mutex.Lock()
+	for i, e := range progresses {
+		if e == p {
+			progresses = append(progresses[:i], progresses[i+1:]...)
+			break
+		}
+	}
+	mutex.Unlock()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.gauge.Finish()
+	p.Clear()
+	p.gauge.Clear()
 }
 
-// FinishTask finishes the file control task.
-func FinishTask() {
-	progress.done++
+// Update progressing by a value.
+func (p *Progress) Update(value float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.gauge.Update(value)
 }
 
-// StartTaskCount starts the task count.
-func StartTaskCount(count int) {
-	progress.done = 0
-	progress.taskCount = count
+// StartTask starts the file control task.
+func (p *Progress) StartTask(fi os.FileInfo) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.task = fi
 }
 
-type progressWindow struct {
-	*widget.Window
-	gauge     *widget.ProgressGauge
-	task      os.FileInfo
-	taskCount int
-	done      int
+// FinishTask finishes the file control task.
+func (p *Progress) FinishTask() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.done++
+}
+
+func (p *Progress) draw() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.drawTask()
+	p.gauge.Draw()
 }
 
-func (w *progressWindow) drawTask() {
-	if w.task == nil {
+func (p *Progress) drawTask() {
+	p.Clear()
+	if p.task == nil {
 		return
 	}
-	w.Clear()
 
-	size := util.FormatSize(w.task.Size())
+	size := util.FormatSize(p.task.Size())
 
-	x, y := w.LeftTop()
-	name := w.task.Name()
-	s := fmt.Sprintf("Progress %d/%d (%sB): %s", w.done+1, w.taskCount, size, name)
-	s = runewidth.Truncate(s, w.Width(), "~")
+	x, y := p.LeftTop()
+	name := p.task.Name()
+	s := fmt.Sprintf("Progress %d/%d (%sB): %s", p.done+1, p.taskCount, size, name)
+	s = runewidth.Truncate(s, p.Width(), "~")
 	widget.SetCells(x, y, s, look.Default())
 }
@@ -0,0 +1,254 @@
+// Package trash moves files to the trash can following the FreeDesktop.org
+// trash specification.
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Trash is a trash directory containing the files and info directories.
+type Trash struct {
+	Dir string // path of the trash directory
+	Top string // top directory of the mount point or "" for the home trash
+}
+
+// Files returns the directory path of trashed files.
+func (t *Trash) Files() string { return filepath.Join(t.Dir, "files") }
+
+// Info returns the directory path of trash info files.
+func (t *Trash) Info() string { return filepath.Join(t.Dir, "info") }
+
+// Home returns the home trash in $XDG_DATA_HOME/Trash.
+func Home() (*Trash, error) {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return &Trash{Dir: filepath.Join(data, "Trash"), Top: ""}, nil
+}
+
+// Supported reports whether the platform has the trash.  Move always fails
+// unless supported.
+func Supported() bool { return supported }
+
+const timeFormat = "2006-01-02T15:04:05"
+
+// Move moves the file to the home trash or the trash of the top directory if
+// the file is on the other mount, and returns the trashed file path.
+func Move(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return "", err
+	}
+	t, err := trashFor(path)
+	if err != nil {
+		return "", err
+	}
+	return t.put(path, time.Now())
+}
+
+func (t *Trash) init() error {
+	if err := os.MkdirAll(t.Files(), 0700); err != nil {
+		return err
+	}
+	return os.MkdirAll(t.Info(), 0700)
+}
+
+// put moves the file to the trash after writing the trash info file.  The name
+// in the trash is suffixed by a number if the same name already exists.
+func (t *Trash) put(path string, deleted time.Time) (string, error) {
+	if err := t.init(); err != nil {
+		return "", err
+	}
+	origin := path
+	if t.Top != "" {
+		rel, err := filepath.Rel(t.Top, path)
+		if err != nil {
+			return "", err
+		}
+		origin = rel
+	}
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: origin}).EscapedPath(), deleted.Format(timeFormat))
+
+	base := filepath.Base(path)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		info := filepath.Join(t.Info(), name+".trashinfo")
+		file, err := os.OpenFile(info, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		_, err = file.WriteString(content)
+		if e := file.Close(); err == nil {
+			err = e
+		}
+		if err != nil {
+			_ = os.Remove(info)
+			return "", err
+		}
+
+		trashed := filepath.Join(t.Files(), name)
+		if err := os.Rename(path, trashed); err != nil {
+			_ = os.Remove(info)
+			return "", err
+		}
+		return trashed, nil
+	}
+}
+
+// Entry is a trashed file with the trash info.
+type Entry struct {
+	Trash   *Trash
+	Name    string    // file name in the trash
+	Path    string    // original absolute path
+	Deleted time.Time // deletion date
+}
+
+// File returns the trashed file path.
+func (e *Entry) File() string { return filepath.Join(e.Trash.Files(), e.Name) }
+
+// InfoFile returns the trash info file path.
+func (e *Entry) InfoFile() string { return filepath.Join(e.Trash.Info(), e.Name+".trashinfo") }
+
+// Restore moves the trashed file to the original path and removes the trash
+// info.  Returns an error if the original path already exists.
+func (e *Entry) Restore() error {
+	if _, err := os.Lstat(e.Path); err == nil {
+		return &os.PathError{Op: "restore", Path: e.Path, Err: os.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(e.File(), e.Path); err != nil {
+		return err
+	}
+	return os.Remove(e.InfoFile())
+}
+
+// Remove deletes the trashed file permanently and the trash info.
+func (e *Entry) Remove() error {
+	if err := os.RemoveAll(e.File()); err != nil {
+		return err
+	}
+	return os.Remove(e.InfoFile())
+}
+
+// Entries returns entries having both the trashed file and the trash info.
+func (t *Trash) Entries() ([]*Entry, error) {
+	infos, err := ioutil.ReadDir(t.Info())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Entry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(infos))
+	for _, info := range infos {
+		if !strings.HasSuffix(info.Name(), ".trashinfo") {
+			continue
+		}
+		name := strings.TrimSuffix(info.Name(), ".trashinfo")
+		e, err := t.entry(name)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(e.File()); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (t *Trash) entry(name string) (*Entry, error) {
+	e := &Entry{Trash: t, Name: name}
+	data, err := ioutil.ReadFile(e.InfoFile())
+	if err != nil {
+		return nil, err
+	}
+	path, deleted, err := parseInfo(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.InfoFile(), err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(t.Top, path)
+	}
+	e.Path = path
+	e.Deleted = deleted
+	return e, nil
+}
+
+func parseInfo(data string) (path string, deleted time.Time, err error) {
+	lines := strings.Split(data, "\n")
+	if strings.TrimSpace(lines[0]) != "[Trash Info]" {
+		return "", deleted, errors.New("invalid trash info")
+	}
+	for _, line := range lines[1:] {
+		switch {
+		case strings.HasPrefix(line, "Path="):
+			path, err = url.PathUnescape(strings.TrimPrefix(line, "Path="))
+			if err != nil {
+				return "", deleted, err
+			}
+		case strings.HasPrefix(line, "DeletionDate="):
+			date := strings.TrimPrefix(line, "DeletionDate=")
+			deleted, _ = time.ParseInLocation(timeFormat, strings.TrimSpace(date), time.Local)
+		}
+	}
+	if path == "" {
+		return "", deleted, errors.New("no path in trash info")
+	}
+	return path, deleted, nil
+}
+
+// List returns entries of the home trash and trashes of mounted top directories.
+func List() ([]*Entry, error) {
+	entries := []*Entry{}
+	for _, t := range trashes() {
+		e, err := t.Entries()
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e...)
+	}
+	return entries, nil
+}
+
+// Lookup returns the entry of the trashed file path.
+func Lookup(file string) (*Entry, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	files := filepath.Dir(file)
+	if filepath.Base(files) != "files" {
+		return nil, fmt.Errorf("%s is not in the trash", file)
+	}
+	dir := filepath.Dir(files)
+	for _, t := range trashes() {
+		if t.Dir == dir {
+			return t.entry(filepath.Base(file))
+		}
+	}
+	return nil, fmt.Errorf("%s is not in the trash", file)
+}
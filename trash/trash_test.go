@@ -0,0 +1,122 @@
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPut(t *testing.T) {
+	dir := t.TempDir()
+	trash := &Trash{Dir: filepath.Join(dir, "Trash"), Top: ""}
+	deleted := time.Date(2021, 12, 1, 10, 20, 30, 0, time.Local)
+
+	for i, want := range []string{"a b.txt", "a b.txt.2", "a b.txt.3"} {
+		path := filepath.Join(dir, "a b.txt")
+		if err := ioutil.WriteFile(path, []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+		trashed, err := trash.put(path, deleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trashed != filepath.Join(trash.Files(), want) {
+			t.Errorf("put(%q)=%q, want %q", path, trashed, want)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%q remains after put", path)
+		}
+		info, err := ioutil.ReadFile(filepath.Join(trash.Info(), want+".trashinfo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := "[Trash Info]\nPath=" + strings.Replace(path, " ", "%20", -1) +
+			"\nDeletionDate=2021-12-01T10:20:30\n"
+		if string(info) != expect {
+			t.Errorf("trash info %q, want %q", info, expect)
+		}
+	}
+}
+
+func TestPutRelative(t *testing.T) {
+	top := t.TempDir()
+	trash := &Trash{Dir: filepath.Join(top, ".Trash-1000"), Top: top}
+	path := filepath.Join(top, "dir", "file")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trash.put(path, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ioutil.ReadFile(filepath.Join(trash.Info(), "file.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(info), "\nPath=dir/file\n") {
+		t.Errorf("trash info %q, want relative path dir/file", info)
+	}
+}
+
+func TestEntriesRestore(t *testing.T) {
+	dir := t.TempDir()
+	trash := &Trash{Dir: filepath.Join(dir, "Trash"), Top: ""}
+	path := filepath.Join(dir, "sub dir", "file")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deleted := time.Date(2021, 12, 1, 10, 20, 30, 0, time.Local)
+	if _, err := trash.put(path, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := trash.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Entries() returns %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Path != path || !e.Deleted.Equal(deleted) {
+		t.Errorf("entry %q %v, want %q %v", e.Path, e.Deleted, path, deleted)
+	}
+	if err := e.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("restored file %q %v, want data", data, err)
+	}
+	if entries, _ := trash.Entries(); len(entries) != 0 {
+		t.Errorf("Entries() returns %d entries after restore, want 0", len(entries))
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		path string
+		ok   bool
+	}{
+		{"[Trash Info]\nPath=/a%20b\nDeletionDate=2021-12-01T10:20:30\n", "/a b", true},
+		{"[Trash Info]\nPath=dir/file\n", "dir/file", true},
+		{"[Trash Info]\nDeletionDate=2021-12-01T10:20:30\n", "", false},
+		{"Path=/a\n", "", false},
+	} {
+		path, _, err := parseInfo(c.data)
+		if (err == nil) != c.ok || path != c.path {
+			t.Errorf("parseInfo(%q)=%q, %v, want %q", c.data, path, err, c.path)
+		}
+	}
+}
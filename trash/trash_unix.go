@@ -0,0 +1,146 @@
+//go:build !windows
+// +build !windows
+
+package trash
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const supported = true
+
+// trashFor returns the home trash if the path is on the same mount as the home
+// trash, otherwise the trash of the top directory of the mount point.
+func trashFor(path string) (*Trash, error) {
+	home, err := Home()
+	if err != nil {
+		return nil, err
+	}
+	if err := home.init(); err != nil {
+		return nil, err
+	}
+	homedev, err := device(home.Dir)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := device(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if dev == homedev {
+		return home, nil
+	}
+	top, err := mountTop(path, dev)
+	if err != nil {
+		return nil, err
+	}
+	return topTrash(top)
+}
+
+// topTrash returns $top/.Trash/$uid if $top/.Trash is a sticky directory and
+// not a symlink, otherwise $top/.Trash-$uid.
+func topTrash(top string) (*Trash, error) {
+	uid := os.Getuid()
+	admin := filepath.Join(top, ".Trash")
+	if lstat, err := os.Lstat(admin); err == nil {
+		if lstat.IsDir() && lstat.Mode()&os.ModeSticky != 0 {
+			t := &Trash{Dir: filepath.Join(admin, fmt.Sprint(uid)), Top: top}
+			if err := t.init(); err == nil {
+				return t, nil
+			}
+		}
+	}
+	t := &Trash{Dir: filepath.Join(top, fmt.Sprintf(".Trash-%d", uid)), Top: top}
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// mountTop returns the top directory of the mount point containing the path.
+func mountTop(path string, dev uint64) (string, error) {
+	top := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(top)
+		if parent == top {
+			return top, nil
+		}
+		d, err := device(parent)
+		if err != nil {
+			return "", err
+		}
+		if d != dev {
+			return top, nil
+		}
+		top = parent
+	}
+}
+
+func device(path string) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return uint64(stat.Dev), nil
+}
+
+// trashes returns the home trash and existing trashes of mounted top
+// directories listed in /proc/self/mounts.
+func trashes() []*Trash {
+	ts := []*Trash{}
+	if home, err := Home(); err == nil {
+		ts = append(ts, home)
+	}
+	uid := os.Getuid()
+	for _, top := range mountPoints() {
+		for _, dir := range []string{
+			filepath.Join(top, ".Trash", fmt.Sprint(uid)),
+			filepath.Join(top, fmt.Sprintf(".Trash-%d", uid)),
+		} {
+			if stat, err := os.Stat(dir); err == nil && stat.IsDir() {
+				ts = append(ts, &Trash{Dir: dir, Top: top})
+			}
+		}
+	}
+	return ts
+}
+
+func mountPoints() []string {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return []string{}
+	}
+	defer file.Close()
+	points := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		points = append(points, unescapeMount(fields[1]))
+	}
+	return points
+}
+
+// unescapeMount unescapes octal sequences such as \040 in the mount point.
+func unescapeMount(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
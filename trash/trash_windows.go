@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package trash
+
+import "errors"
+
+const supported = false
+
+func trashFor(path string) (*Trash, error) {
+	return nil, errors.New("trash is not supported on windows")
+}
+
+func trashes() []*Trash {
+	return []*Trash{}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	fuzzyScoreMatch        = 16
+	fuzzyScoreGapStart     = -3
+	fuzzyScoreGapExtension = -1
+	fuzzyBonusBoundary     = 8
+	fuzzyBonusCamel        = 7
+	fuzzyBonusConsecutive  = 4
+	fuzzyBonusFirstFactor  = 2
+)
+
+// FuzzyMatch reports whether runes of the pattern appear in the string in
+// order like fzf, and returns the score and the rune indices of the matched
+// characters.  Matches at the start of words and consecutive matches score
+// higher, and gaps between matches score lower.  Lower case patterns ignore
+// case.
+func FuzzyMatch(pattern, s string) (score int, pos []int, ok bool) {
+	pat := []rune(pattern)
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	fold := pattern == strings.ToLower(pattern)
+	text := []rune(s)
+	equal := func(p, r rune) bool {
+		if fold {
+			r = unicode.ToLower(r)
+		}
+		return p == r
+	}
+
+	// find the end of the first match
+	end, j := -1, 0
+	for i, r := range text {
+		if equal(pat[j], r) {
+			j++
+			if j == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	// shorten the match scanning backward from the end
+	pos = make([]int, len(pat))
+	j = len(pat) - 1
+	for i := end; i >= 0 && j >= 0; i-- {
+		if equal(pat[j], text[i]) {
+			pos[j] = i
+			j--
+		}
+	}
+	return fuzzyScore(text, pos), pos, true
+}
+
+func fuzzyScore(text []rune, pos []int) int {
+	score, first := 0, 0 // first is the bonus of the start of consecutive matches
+	for i, p := range pos {
+		score += fuzzyScoreMatch
+		bonus := fuzzyBonus(text, p)
+		if i > 0 && p == pos[i-1]+1 {
+			if first < fuzzyBonusConsecutive {
+				first = fuzzyBonusConsecutive
+			}
+			if bonus < first {
+				bonus = first
+			}
+		} else {
+			if i > 0 {
+				score += fuzzyScoreGapStart + fuzzyScoreGapExtension*(p-pos[i-1]-2)
+			}
+			first = bonus
+		}
+		if i == 0 {
+			bonus *= fuzzyBonusFirstFactor
+		}
+		score += bonus
+	}
+	return score
+}
+
+// fuzzyBonus returns the bonus of the rune at the start of a word.
+func fuzzyBonus(text []rune, i int) int {
+	if i == 0 {
+		return fuzzyBonusBoundary
+	}
+	prev, r := text[i-1], text[i]
+	switch {
+	case isWordSeparator(prev) && !isWordSeparator(r):
+		return fuzzyBonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(r),
+		!unicode.IsDigit(prev) && unicode.IsDigit(r):
+		return fuzzyBonusCamel
+	}
+	return 0
+}
+
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("/\\_-.,:;", r)
+}
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Gitignore matches paths by patterns of .gitignore files in a tree.
+// Patterns added later take precedence, so add patterns of parent directories
+// before subdirectories.
+type Gitignore struct {
+	rules []gitignoreRule
+}
+
+type gitignoreRule struct {
+	base   string // slash directory of the .gitignore relative to the root or ""
+	re     *regexp.Regexp
+	negate bool // re-includes the matched path
+	dir    bool // matches only directories
+}
+
+// Add patterns of the .gitignore file content in the slash directory relative
+// to the root, or "" for the root.  Invalid patterns are ignored.
+func (g *Gitignore) Add(base string, data []byte) {
+	base = strings.Trim(base, "/")
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rule, ok := parseGitignore(scanner.Text()); ok {
+			rule.base = base
+			g.rules = append(g.rules, rule)
+		}
+	}
+}
+
+// Match reports whether the slash path relative to the root is ignored.
+func (g *Gitignore) Match(path string, isDir bool) bool {
+	path = strings.Trim(path, "/")
+	for i := len(g.rules) - 1; i >= 0; i-- {
+		r := g.rules[i]
+		if r.dir && !isDir {
+			continue
+		}
+		rel := path
+		if r.base != "" {
+			if !strings.HasPrefix(path, r.base+"/") {
+				continue
+			}
+			rel = path[len(r.base)+1:]
+		}
+		if r.re.MatchString(rel) {
+			return !r.negate
+		}
+	}
+	return false
+}
+
+func parseGitignore(line string) (gitignoreRule, bool) {
+	rule := gitignoreRule{}
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dir = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+	// patterns with a slash except at the end match from the directory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := gitignoreRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return rule, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// gitignoreRegexp converts the wildcard pattern to the regexp.
+func gitignoreRegexp(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case pattern[i:] == "**" && i > 0 && pattern[i-1] == '/':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return b.String()
+}
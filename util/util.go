@@ -2,6 +2,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -60,6 +61,15 @@ func ShortenPath(path string, width int) string {
 	return path
 }
 
+// IsSubpath reports whether the path is the base directory or under it.
+func IsSubpath(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // RemoveExt removes extension from the name.
 func RemoveExt(name string) string {
 	if ext := filepath.Ext(name); ext != name {
@@ -159,17 +169,30 @@ func SearchCommands() (map[string]bool, error) {
 // CalcSizeCount calculates files total size and count, excluding themself of
 // directories and links.
 func CalcSizeCount(src ...string) (int64, int) {
+	size, count, _ := CalcSizeCountContext(context.Background(), src...)
+	return size, count
+}
+
+// CalcSizeCountContext is CalcSizeCount stopping the walk and returning the
+// context error when the context is canceled.  Unreadable files are skipped.
+func CalcSizeCountContext(ctx context.Context, src ...string) (int64, int, error) {
 	size := int64(0)
 	count := 0
 	for _, s := range src {
-		_ = filepath.Walk(s, func(path string, fi os.FileInfo, err error) error {
-			if fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 {
+		err := filepath.Walk(s, func(path string, fi os.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil || fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 {
 				return nil
 			}
 			size += fi.Size()
 			count++
 			return nil
 		})
+		if err != nil {
+			return size, count, err
+		}
 	}
-	return size, count
+	return size, count, nil
 }
@@ -1,8 +1,12 @@
 package util
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -78,3 +82,136 @@ func TestRemoveExt(t *testing.T) {
 		}
 	}
 }
+
+func TestIsSubpath(t *testing.T) {
+	for _, d := range []struct {
+		base   string
+		path   string
+		result bool
+	}{
+		{"/home/abc", "/home/abc", true},
+		{"/home/abc", "/home/abc/def", true},
+		{"/home/abc/", "/home/abc/def/hij", true},
+		{"/home/abc", "/home/abcdef", false},
+		{"/home/abc", "/home", false},
+		{"/home/abc", "/home/def/abc", false},
+		{"/", "/home", true},
+	} {
+		if r := IsSubpath(d.base, d.path); r != d.result {
+			t.Errorf("IsSubpath(%q, %q)=%v, want %v", d.base, d.path, r, d.result)
+		}
+	}
+}
+
+func TestCalcSizeCountContext(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "sub/b"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size, count, err := CalcSizeCountContext(context.Background(), dir); size != 8 || count != 2 || err != nil {
+		t.Errorf("CalcSizeCountContext()=%d, %d, %v, want 8, 2, nil", size, count, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := CalcSizeCountContext(ctx, dir); err != context.Canceled {
+		t.Errorf("CalcSizeCountContext() canceled returns %v", err)
+	}
+	if size, count := CalcSizeCount(filepath.Join(dir, "missing")); size != 0 || count != 0 {
+		t.Errorf("CalcSizeCount(missing)=%d, %d", size, count)
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, d := range []struct {
+		pattern string
+		s       string
+		pos     []int
+		ok      bool
+	}{
+		{"", "abc", nil, true},
+		{"ac", "abc", []int{0, 2}, true},
+		{"ca", "abc", nil, false},
+		{"mgo", "main.go", []int{0, 5, 6}, true},
+		{"fb", "foo_bar", []int{0, 4}, true},
+		{"Fb", "foo_bar", nil, false},
+		{"FB", "FooBar", []int{0, 3}, true},
+		{"abc", "a_abc", []int{2, 3, 4}, true}, // shortest match
+		{"あう", "あいう", []int{0, 2}, true},
+	} {
+		_, pos, ok := FuzzyMatch(d.pattern, d.s)
+		if ok != d.ok || !reflect.DeepEqual(pos, d.pos) {
+			t.Errorf("FuzzyMatch(%q, %q)=%v, %v, want %v, %v", d.pattern, d.s, pos, ok, d.pos, d.ok)
+		}
+	}
+
+	score := func(pattern, s string) int {
+		score, _, _ := FuzzyMatch(pattern, s)
+		return score
+	}
+	for _, d := range []struct {
+		pattern string
+		better  string
+		worse   string
+	}{
+		{"fb", "foo_bar", "fabric"},      // word start
+		{"fb", "FooBar", "fiber"},        // camel case
+		{"abc", "abc.txt", "a_b_c.txt"},  // consecutive
+		{"go", "goful", "program.log.o"}, // gap
+	} {
+		if b, w := score(d.pattern, d.better), score(d.pattern, d.worse); b <= w {
+			t.Errorf("FuzzyMatch(%q) scores %q %d <= %q %d", d.pattern, d.better, b, d.worse, w)
+		}
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	g := &Gitignore{}
+	g.Add("", []byte(`# comment
+*.log
+!keep.log
+build/
+/root.txt
+doc/*.html
+**/tmp
+a/**/z
+\#hash
+[ab].c
+`))
+	g.Add("sub", []byte("*.txt\n"))
+	for _, d := range []struct {
+		path   string
+		isDir  bool
+		result bool
+	}{
+		{"x.log", false, true},
+		{"dir/x.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"root.txt", false, true},
+		{"dir/root.txt", false, false},
+		{"doc/a.html", false, true},
+		{"doc/sub/a.html", false, false},
+		{"tmp", true, true},
+		{"x/y/tmp", false, true},
+		{"a/z", false, true},
+		{"a/b/c/z", false, true},
+		{"#hash", false, true},
+		{"a.c", false, true},
+		{"c.c", false, false},
+		{"sub/x.txt", false, true},
+		{"sub/dir/x.txt", false, true},
+		{"x.txt", false, false},
+		{"あ.log", false, true},
+	} {
+		if r := g.Match(d.path, d.isDir); r != d.result {
+			t.Errorf("Match(%q, %v)=%v, want %v", d.path, d.isDir, r, d.result)
+		}
+	}
+}
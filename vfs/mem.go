@@ -0,0 +1,281 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mem is an in-memory file system rooted at the path separator.  Symlinks are
+// not supported.
+type Mem struct {
+	mu    sync.Mutex
+	files map[string]*memFile // key: cleaned full path
+}
+
+type memFile struct {
+	name    string
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMem creates a new in-memory file system with the empty root directory.
+func NewMem() *Mem {
+	root := string(filepath.Separator)
+	return &Mem{files: map[string]*memFile{
+		root: {name: root, mode: os.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+var errNotEmpty = errors.New("directory not empty")
+
+func (m *Mem) String() string { return "mem:" }
+
+// lookup returns the file of the path.  Call it with locking.
+func (m *Mem) lookup(op, name string) (*memFile, error) {
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return f, nil
+}
+
+// parent checks the parent directory of the path exists.  Call it with
+// locking.
+func (m *Mem) parent(op, name string) error {
+	f, ok := m.files[filepath.Dir(filepath.Clean(name))]
+	if !ok {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if !f.mode.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+	}
+	return nil
+}
+
+// children returns cleaned paths of entries in the directory sorted by name.
+// Call it with locking.
+func (m *Mem) children(dir string) []string {
+	paths := []string{}
+	for path := range m.files {
+		if path != dir && filepath.Dir(path) == dir {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ReadDir calls fn for each entry of the directory sorted by name.
+func (m *Mem) ReadDir(dir string, fn func(os.FileInfo) bool) error {
+	m.mu.Lock()
+	f, err := m.lookup("open", dir)
+	if err == nil && !f.mode.IsDir() {
+		err = &os.PathError{Op: "readdir", Path: dir, Err: errors.New("not a directory")}
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	fis := []os.FileInfo{}
+	for _, path := range m.children(filepath.Clean(dir)) {
+		fis = append(fis, m.files[path].info())
+	}
+	m.mu.Unlock()
+	for _, fi := range fis {
+		if !fn(fi) {
+			break
+		}
+	}
+	return nil
+}
+
+// Lstat returns the file info of the path.
+func (m *Mem) Lstat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info(), nil
+}
+
+// Stat returns the file info of the path same as Lstat.
+func (m *Mem) Stat(name string) (os.FileInfo, error) { return m.Lstat(name) }
+
+// Open opens the file for reading a copy of the contents.
+func (m *Mem) Open(name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return ioutil.NopCloser(bytes.NewReader(append([]byte{}, f.data...))), nil
+}
+
+// Create creates or truncates the file and writes the contents on closing.
+func (m *Mem) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.parent("open", name); err != nil {
+		return nil, err
+	}
+	if f, err := m.lookup("open", name); err == nil {
+		if f.mode.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		}
+		perm = f.mode
+	}
+	m.put(name, perm.Perm(), nil)
+	return &memWriter{mem: m, name: name, perm: perm.Perm()}, nil
+}
+
+// put sets the file of the path.  Call it with locking.
+func (m *Mem) put(name string, mode os.FileMode, data []byte) {
+	name = filepath.Clean(name)
+	m.files[name] = &memFile{filepath.Base(name), mode, time.Now(), data}
+}
+
+type memWriter struct {
+	bytes.Buffer
+	mem  *Mem
+	name string
+	perm os.FileMode
+}
+
+func (w *memWriter) Close() error {
+	w.mem.mu.Lock()
+	defer w.mem.mu.Unlock()
+	w.mem.put(w.name, w.perm, w.Bytes())
+	return nil
+}
+
+// WriteFile writes the data to the file creating parent directories.
+func (m *Mem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := m.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(name, perm.Perm(), append([]byte{}, data...))
+	return nil
+}
+
+// Mkdir creates the directory.
+func (m *Mem) Mkdir(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookup("mkdir", name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if err := m.parent("mkdir", name); err != nil {
+		return err
+	}
+	m.put(name, os.ModeDir|perm.Perm(), nil)
+	return nil
+}
+
+// MkdirAll creates the directory and the parents if not exist.
+func (m *Mem) MkdirAll(name string, perm os.FileMode) error {
+	if fi, err := m.Stat(name); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+	}
+	if parent := filepath.Dir(filepath.Clean(name)); parent != filepath.Clean(name) {
+		if err := m.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	return m.Mkdir(name, perm)
+}
+
+// Rename moves the file or the directory tree replacing the new file.
+func (m *Mem) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookup("rename", oldname); err != nil {
+		return err
+	}
+	if err := m.parent("rename", newname); err != nil {
+		return err
+	}
+	oldname, newname = filepath.Clean(oldname), filepath.Clean(newname)
+	if f, ok := m.files[newname]; ok && f.mode.IsDir() && len(m.children(newname)) > 0 {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errNotEmpty}
+	}
+	prefix := oldname + string(filepath.Separator)
+	moved := map[string]*memFile{}
+	for path, f := range m.files {
+		if path == oldname || strings.HasPrefix(path, prefix) {
+			delete(m.files, path)
+			moved[newname+strings.TrimPrefix(path, oldname)] = f
+		}
+	}
+	for path, f := range moved {
+		f.name = filepath.Base(path)
+		m.files[path] = f
+	}
+	return nil
+}
+
+// Remove removes the file or the empty directory.
+func (m *Mem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	name = filepath.Clean(name)
+	if f.mode.IsDir() && len(m.children(name)) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// Chtimes sets the modification time of the file.  The access time is not
+// recorded.
+func (m *Mem) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	f.modTime = mtime
+	return nil
+}
+
+func (f *memFile) info() os.FileInfo {
+	return memInfo{f.name, int64(len(f.data)), f.mode, f.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi memInfo) Name() string       { return fi.name }
+func (fi memInfo) Size() int64        { return fi.size }
+func (fi memInfo) Mode() os.FileMode  { return fi.mode }
+func (fi memInfo) ModTime() time.Time { return fi.modTime }
+func (fi memInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi memInfo) Sys() interface{}   { return nil }
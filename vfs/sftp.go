@@ -0,0 +1,157 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// SFTP is a file system on a remote host over SSH.  Paths are converted to
+// slash separated paths on the host.
+type SFTP struct {
+	client *sftp.Client
+	conn   io.Closer // SSH connection closed with the client or nil
+	name   string
+}
+
+// NewSFTP creates a new file system by the SFTP client named as the URL
+// prefix such as "sftp://host".  The connection is closed with the client if
+// not nil.
+func NewSFTP(client *sftp.Client, conn io.Closer, name string) *SFTP {
+	return &SFTP{client: client, conn: conn, name: name}
+}
+
+// String returns the URL prefix of the host.
+func (s *SFTP) String() string { return s.name }
+
+// Close the connection and the client waiting for the connection closed.
+func (s *SFTP) Close() error {
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+	}
+	if cerr := s.client.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Getwd returns the working directory on the host, usually the home directory.
+func (s *SFTP) Getwd() (string, error) { return s.client.Getwd() }
+
+// Alive reports whether the connection works.
+func (s *SFTP) Alive() bool {
+	_, err := s.client.Getwd()
+	return err == nil
+}
+
+func (s *SFTP) path(name string) string { return filepath.ToSlash(name) }
+
+func pathError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// ReadDir calls fn for each entry of the directory read at once.
+func (s *SFTP) ReadDir(dir string, fn func(os.FileInfo) bool) error {
+	fis, err := s.client.ReadDir(s.path(dir))
+	if err != nil {
+		return pathError("readdir", dir, err)
+	}
+	for _, fi := range fis {
+		if !fn(fi) {
+			break
+		}
+	}
+	return nil
+}
+
+// Lstat returns the file info not following the symlink.
+func (s *SFTP) Lstat(name string) (os.FileInfo, error) {
+	fi, err := s.client.Lstat(s.path(name))
+	return fi, pathError("lstat", name, err)
+}
+
+// Stat returns the file info following the symlink.
+func (s *SFTP) Stat(name string) (os.FileInfo, error) {
+	fi, err := s.client.Stat(s.path(name))
+	return fi, pathError("stat", name, err)
+}
+
+// Open opens the file for reading.
+func (s *SFTP) Open(name string) (io.ReadCloser, error) {
+	f, err := s.client.Open(s.path(name))
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return f, nil
+}
+
+// Create creates or truncates the file.  The permission is set only if created
+// like the local disk.
+func (s *SFTP) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	_, statErr := s.client.Lstat(s.path(name))
+	f, err := s.client.Create(s.path(name))
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	if statErr != nil {
+		if err := s.client.Chmod(s.path(name), perm.Perm()); err != nil {
+			f.Close()
+			return nil, pathError("chmod", name, err)
+		}
+	}
+	return f, nil
+}
+
+// Mkdir creates the directory.
+func (s *SFTP) Mkdir(name string, perm os.FileMode) error {
+	if err := s.client.Mkdir(s.path(name)); err != nil {
+		if _, serr := s.client.Lstat(s.path(name)); serr == nil {
+			err = os.ErrExist // servers report only failure
+		}
+		return pathError("mkdir", name, err)
+	}
+	return pathError("chmod", name, s.client.Chmod(s.path(name), perm.Perm()))
+}
+
+// Rename renames the file.  Servers may fail if the new file exists.
+func (s *SFTP) Rename(oldname, newname string) error {
+	if err := s.client.Rename(s.path(oldname), s.path(newname)); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// Remove removes the file or the empty directory.
+func (s *SFTP) Remove(name string) error {
+	return pathError("remove", name, s.client.Remove(s.path(name)))
+}
+
+// Chtimes sets the access and modification times of the file.
+func (s *SFTP) Chtimes(name string, atime, mtime time.Time) error {
+	return pathError("chtimes", name, s.client.Chtimes(s.path(name), atime, mtime))
+}
+
+// Readlink returns the destination of the symlink.
+func (s *SFTP) Readlink(name string) (string, error) {
+	link, err := s.client.ReadLink(s.path(name))
+	return filepath.FromSlash(link), pathError("readlink", name, err)
+}
+
+// Symlink creates the new file as a symlink to the old file.
+func (s *SFTP) Symlink(oldname, newname string) error {
+	if err := s.client.Symlink(filepath.ToSlash(oldname), s.path(newname)); err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// IsSFTPURL reports whether the path is an SFTP URL.
+func IsSFTPURL(path string) bool { return strings.HasPrefix(path, "sftp://") }
@@ -0,0 +1,134 @@
+package vfs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// pipeConn joins a reader and a writer of pipes as a connection.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+func newTestSFTP(t *testing.T) *SFTP {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	server, err := sftp.NewServer(pipeConn{sr, sw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	client, err := sftp.NewClientPipe(cr, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSFTP(client, sw, "sftp://test") // closing the server ends the client
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSFTP(t *testing.T) {
+	testFS(t, newTestSFTP(t), t.TempDir())
+}
+
+func TestParseSFTPURL(t *testing.T) {
+	for _, c := range []struct {
+		url  string
+		addr SSHAddr
+		path string
+	}{
+		{"sftp://host", SSHAddr{"", "host", ""}, ""},
+		{"sftp://user@host:2222/var/www", SSHAddr{"user", "host", "2222"}, "/var/www"},
+		{"sftp://[::1]:22/", SSHAddr{"", "::1", "22"}, "/"},
+	} {
+		addr, path, err := ParseSFTPURL(c.url)
+		if err != nil || addr != c.addr || path != c.path {
+			t.Errorf("ParseSFTPURL(%q)=%v, %q, %v", c.url, addr, path, err)
+		}
+		if !strings.HasPrefix(c.url, addr.String()) {
+			t.Errorf("%v.String()=%q, want the prefix of %q", addr, addr.String(), c.url)
+		}
+	}
+	for _, url := range []string{"sftp:///path", "http://host/"} {
+		if _, _, err := ParseSFTPURL(url); err == nil {
+			t.Errorf("ParseSFTPURL(%q) returns no error", url)
+		}
+	}
+}
+
+type testSSHConfig map[string][]string
+
+func (c testSSHConfig) Get(alias, key string) string {
+	if v := c[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c testSSHConfig) GetAll(alias, key string) []string { return c[key] }
+
+func TestSSHClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := "[example.com]:2222 " + string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+	if err := ioutil.WriteFile(knownHosts, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(dir, "id")
+	if err := ioutil.WriteFile(identity, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := testSSHConfig{
+		"HostName":           {"example.com"},
+		"Port":               {"2222"},
+		"User":               {"admin"},
+		"IdentityFile":       {identity, filepath.Join(dir, "none")},
+		"UserKnownHostsFile": {knownHosts},
+	}
+
+	conf, hostport, err := sshClientConfig(config, SSHAddr{Host: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostport != "example.com:2222" || conf.User != "admin" {
+		t.Errorf("dial %s@%s", conf.User, hostport)
+	}
+	if len(conf.HostKeyAlgorithms) != 1 || conf.HostKeyAlgorithms[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("host key algorithms %q", conf.HostKeyAlgorithms)
+	}
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2222}
+	if err := conf.HostKeyCallback(hostport, remote, signer.PublicKey()); err != nil {
+		t.Errorf("known host key rejected: %v", err)
+	}
+	if signers := identitySigners(config, "web"); len(signers) != 1 {
+		t.Errorf("%d identities, want 1", len(signers))
+	}
+
+	if _, hostport, err := sshClientConfig(config, SSHAddr{Host: "web", Port: "22"}); err == nil {
+		t.Errorf("unknown host %s accepted", hostport)
+	}
+}
@@ -0,0 +1,205 @@
+package vfs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/anmitsu/goful/util"
+	"github.com/kevinburke/ssh_config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHAddr is the address of an SSH host.  Host is a host name or an alias in
+// the ssh config, and User and Port are empty if omitted.
+type SSHAddr struct {
+	User string
+	Host string
+	Port string
+}
+
+// String returns the URL prefix of the address such as "sftp://user@host:22".
+func (a SSHAddr) String() string {
+	u := url.URL{Scheme: "sftp", Host: a.Host}
+	if a.Port != "" {
+		u.Host = net.JoinHostPort(a.Host, a.Port)
+	}
+	if a.User != "" {
+		u.User = url.User(a.User)
+	}
+	return u.String()
+}
+
+// ParseSFTPURL parses the URL "sftp://[user@]host[:port][/path]" into the
+// address and the path, or "" as the home directory if omitted.
+func ParseSFTPURL(s string) (SSHAddr, string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return SSHAddr{}, "", err
+	}
+	if u.Scheme != "sftp" || u.Hostname() == "" {
+		return SSHAddr{}, "", fmt.Errorf("invalid sftp url %s", s)
+	}
+	addr := SSHAddr{Host: u.Hostname(), Port: u.Port()}
+	if u.User != nil {
+		addr.User = u.User.Username()
+	}
+	return addr, u.Path, nil
+}
+
+// sshConfig gets values of the ssh config for the host alias.
+type sshConfig interface {
+	Get(alias, key string) string
+	GetAll(alias, key string) []string
+}
+
+// DialSFTP connects to the host authenticating by the ssh agent and identity
+// files in ~/.ssh/config.  The host key is verified by known hosts files.
+func DialSFTP(addr SSHAddr) (*SFTP, error) {
+	config, hostport, err := sshClientConfig(&ssh_config.UserSettings{IgnoreErrors: true}, addr)
+	if err != nil {
+		return nil, err
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			defer conn.Close() // only used while authenticating
+			signers := agent.NewClient(conn).Signers
+			config.Auth = append([]ssh.AuthMethod{ssh.PublicKeysCallback(signers)}, config.Auth...)
+		}
+	}
+	conn, err := ssh.Dial("tcp", hostport, config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return NewSFTP(client, conn, addr.String()), nil
+}
+
+// sshClientConfig returns the client config and the address to dial resolving
+// the host alias by the ssh config.
+func sshClientConfig(config sshConfig, addr SSHAddr) (*ssh.ClientConfig, string, error) {
+	alias := addr.Host
+	host := config.Get(alias, "HostName")
+	if host == "" {
+		host = alias
+	}
+	port := addr.Port
+	if port == "" {
+		port = config.Get(alias, "Port")
+	}
+	if port == "" {
+		port = "22"
+	}
+	name := addr.User
+	if name == "" {
+		name = config.Get(alias, "User")
+	}
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+	}
+	hostport := net.JoinHostPort(host, port)
+
+	files := []string{}
+	for _, f := range strings.Fields(config.Get(alias, "UserKnownHostsFile")) {
+		files = append(files, util.ExpandPath(f))
+	}
+	if len(files) == 0 {
+		files = []string{util.ExpandPath("~/.ssh/known_hosts")}
+	}
+	hostKey, algorithms, err := knownHostKey(files, hostport)
+	if err != nil {
+		return nil, "", err
+	}
+
+	timeout := 15 * time.Second
+	if t := config.Get(alias, "ConnectTimeout"); t != "" {
+		if d, err := time.ParseDuration(t + "s"); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	return &ssh.ClientConfig{
+		User:              name,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(identitySigners(config, alias)...)},
+		HostKeyCallback:   hostKey,
+		HostKeyAlgorithms: algorithms,
+		Timeout:           timeout,
+	}, hostport, nil
+}
+
+// identitySigners returns signers of unencrypted identity files in the ssh
+// config or the default files.  Encrypted keys are expected to be in the agent.
+func identitySigners(config sshConfig, alias string) []ssh.Signer {
+	files := config.GetAll(alias, "IdentityFile")
+	if len(files) == 0 || len(files) == 1 && files[0] == "~/.ssh/identity" { // the default
+		files = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+	}
+	signers := []ssh.Signer{}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(util.ExpandPath(f))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	return signers
+}
+
+// knownHostKey returns the callback verifying the host key by the known hosts
+// files and the algorithms of the known keys to negotiate.
+func knownHostKey(files []string, hostport string) (ssh.HostKeyCallback, []string, error) {
+	exists := []string{}
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			exists = append(exists, f)
+		}
+	}
+	if len(exists) == 0 {
+		return nil, nil, fmt.Errorf("no known hosts files %s", strings.Join(files, " "))
+	}
+	callback, err := knownhosts.New(exists...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// look up known keys by checking a dummy key
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	dummy, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	var keyErr *knownhosts.KeyError
+	remote := &net.TCPAddr{IP: net.IPv4zero}
+	if err := callback(hostport, remote, dummy); !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return nil, nil, fmt.Errorf("unknown host %s: connect by ssh once to add the host key", hostport)
+	}
+	algorithms := []string{}
+	for _, k := range keyErr.Want {
+		if k.Key.Type() == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, k.Key.Type())
+	}
+	return callback, algorithms, nil
+}
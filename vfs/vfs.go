@@ -0,0 +1,212 @@
+// Package vfs provides file systems listed by directories and walked by file
+// operations.  The local disk is the default and other file systems such as
+// an in-memory tree or remote hosts implement the same interface.
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is a file system accessed by full paths.
+type FS interface {
+	// ReadDir calls fn for each entry of the directory without following
+	// symlinks until fn returns false.  Entries are not sorted.
+	ReadDir(dir string, fn func(fi os.FileInfo) bool) error
+	Lstat(name string) (os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	Open(name string) (io.ReadCloser, error)
+	// Create creates or truncates the file for writing.
+	Create(name string, perm os.FileMode) (io.WriteCloser, error)
+	Mkdir(name string, perm os.FileMode) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// String returns the prefix of paths for display such as "sftp://host",
+	// or "" for the local disk.
+	String() string
+}
+
+// Linker is implemented by file systems supporting symlinks.  Symlinks are
+// followed on other file systems.
+type Linker interface {
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+}
+
+// Local is the file system of the local disk.
+var Local FS = local{}
+
+// IsLocal reports whether the file system is the local disk.
+func IsLocal(fsys FS) bool { return fsys == nil || fsys == Local }
+
+type local struct{}
+
+func (local) String() string { return "" }
+
+func (local) ReadDir(dir string, fn func(os.FileInfo) bool) error {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	for {
+		fis, err := fd.Readdir(100)
+		for _, fi := range fis {
+			if !fn(fi) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (local) Lstat(name string) (os.FileInfo, error) { return os.Lstat(name) }
+func (local) Stat(name string) (os.FileInfo, error)  { return os.Stat(name) }
+
+func (local) Open(name string) (io.ReadCloser, error) { return os.Open(name) }
+
+func (local) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+func (local) Mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
+func (local) Rename(oldname, newname string) error      { return os.Rename(oldname, newname) }
+func (local) Remove(name string) error                  { return os.Remove(name) }
+
+func (local) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (local) Readlink(name string) (string, error)  { return os.Readlink(name) }
+func (local) Symlink(oldname, newname string) error { return os.Symlink(oldname, newname) }
+
+// ReadDirAll returns entries of the directory sorted by name.
+func ReadDirAll(fsys FS, dir string) ([]os.FileInfo, error) {
+	fis := []os.FileInfo{}
+	err := fsys.ReadDir(dir, func(fi os.FileInfo) bool {
+		fis = append(fis, fi)
+		return true
+	})
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	return fis, err
+}
+
+// Walk walks the tree of the root like filepath.Walk in the file system.
+func Walk(fsys FS, root string, fn filepath.WalkFunc) error {
+	if IsLocal(fsys) {
+		return filepath.Walk(root, fn)
+	}
+	info, err := fsys.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fsys, root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(fsys FS, path string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	fis, err := ReadDirAll(fsys, path)
+	err1 := fn(path, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+	for _, fi := range fis {
+		err := walk(fsys, filepath.Join(path, fi.Name()), fi, fn)
+		if err != nil {
+			if !fi.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Glob returns paths matching the pattern like filepath.Glob in the file
+// system.
+func Glob(fsys FS, pattern string) ([]string, error) {
+	if IsLocal(fsys) {
+		return filepath.Glob(pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasMeta(dir) {
+		return glob(fsys, dir, file, nil), nil
+	}
+	if dir == pattern { // such as "[" not to recurse forever
+		return nil, filepath.ErrBadPattern
+	}
+	dirs, err := Glob(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = glob(fsys, d, file, matches)
+	}
+	return matches, nil
+}
+
+func glob(fsys FS, dir, pattern string, matches []string) []string {
+	if fi, err := fsys.Stat(dir); err != nil || !fi.IsDir() {
+		return matches
+	}
+	fis, _ := ReadDirAll(fsys, dir)
+	for _, fi := range fis {
+		if ok, _ := filepath.Match(pattern, fi.Name()); ok {
+			matches = append(matches, filepath.Join(dir, fi.Name()))
+		}
+	}
+	return matches
+}
+
+func cleanGlobPath(path string) string {
+	switch path {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return path
+	default:
+		return path[:len(path)-1] // chop off the trailing separator
+	}
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// SizeCount returns the total size and the count of files except directories
+// and symlinks in the trees of the paths like util.CalcSizeCount.
+func SizeCount(fsys FS, paths ...string) (int64, int) {
+	var size int64
+	count := 0
+	for _, path := range paths {
+		_ = Walk(fsys, path, func(_ string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 {
+				return nil
+			}
+			size += fi.Size()
+			count++
+			return nil
+		})
+	}
+	return size, count
+}
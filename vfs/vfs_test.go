@@ -0,0 +1,105 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	testFS(t, Local, t.TempDir())
+
+	mem := NewMem()
+	root := filepath.FromSlash("/tmp/test")
+	if err := mem.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testFS(t, mem, root)
+}
+
+func testFS(t *testing.T, fsys FS, root string) {
+	dir := filepath.Join(root, "dir")
+	if err := fsys.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Mkdir(dir, 0755); !os.IsExist(err) {
+		t.Errorf("%s: Mkdir existing dir returns %v", fsys, err)
+	}
+	file := filepath.Join(dir, "a.txt")
+	w, err := fsys.Create(file, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := fsys.Lstat(file)
+	if err != nil || fi.Size() != 5 || fi.IsDir() || fi.Name() != "a.txt" {
+		t.Errorf("%s: Lstat(%q) = %v, %v", fsys, file, fi, err)
+	}
+	r, err := fsys.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "hello" {
+		t.Errorf("%s: read %q, %v", fsys, data, err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := fsys.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := fsys.Stat(file); !fi.ModTime().Equal(mtime) {
+		t.Errorf("%s: mtime %v, want %v", fsys, fi.ModTime(), mtime)
+	}
+
+	moved := filepath.Join(root, "moved")
+	if err := fsys.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Lstat(file); !os.IsNotExist(err) {
+		t.Errorf("%s: %q remains after Rename", fsys, file)
+	}
+	if matches, err := Glob(fsys, filepath.Join(root, "*", "*.txt")); err != nil ||
+		!reflect.DeepEqual(matches, []string{filepath.Join(moved, "a.txt")}) {
+		t.Errorf("%s: Glob = %q, %v", fsys, matches, err)
+	}
+	if size, count := SizeCount(fsys, root); size != 5 || count != 1 {
+		t.Errorf("%s: SizeCount = %d, %d", fsys, size, count)
+	}
+
+	if err := fsys.Remove(moved); err == nil {
+		t.Errorf("%s: removed the non-empty directory", fsys)
+	}
+	if err := fsys.Remove(filepath.Join(moved, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove(moved); err != nil {
+		t.Fatal(err)
+	}
+	if fis, err := ReadDirAll(fsys, root); err != nil || len(fis) != 0 {
+		t.Errorf("%s: ReadDirAll = %v, %v", fsys, fis, err)
+	}
+	if l, ok := fsys.(Linker); ok && runtime.GOOS != "windows" { // symlinks need privileges on Windows
+		link := filepath.Join(root, "link")
+		if err := l.Symlink(filepath.Join("dir", "a.txt"), link); err != nil {
+			t.Fatal(err)
+		}
+		if dst, err := l.Readlink(link); err != nil || dst != filepath.Join("dir", "a.txt") {
+			t.Errorf("%s: Readlink = %q, %v", fsys, dst, err)
+		}
+		if fi, err := fsys.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s: Lstat of the symlink = %v, %v", fsys, fi, err)
+		}
+	}
+}
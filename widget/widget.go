@@ -273,6 +273,9 @@ func Init() {
 	screen = s
 }
 
+// SetScreen sets the initialized tcell screen such as a simulation screen.
+func SetScreen(s tcell.Screen) { screen = s }
+
 // Fini finishes the tcell screen.
 func Fini() {
 	screen.ShowCursor(0, 0)
@@ -294,6 +297,9 @@ func ShowCursor(x, y int) {
 
 // HideCursor hides the cursor.
 func HideCursor() {
+	if screen == nil {
+		return
+	}
 	screen.HideCursor()
 }
 
@@ -304,6 +310,9 @@ func PollEvent() tcell.Event {
 
 // Show setted cells.
 func Show() {
+	if screen == nil {
+		return
+	}
 	screen.Show()
 }
 